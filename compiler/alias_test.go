@@ -0,0 +1,85 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/generic"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const aliasWriterSchema = `{"type": "record", "name": "Old", "namespace": "com.example", "fields": [
+	{"name": "kind", "type": {"type": "enum", "name": "OldKind", "symbols": ["A", "B"]}},
+	{"name": "hash", "type": {"type": "fixed", "name": "other.OldHash", "size": 2}},
+	{"name": "child", "type": ["null", {"type": "record", "name": "OldChild", "fields": [
+		{"name": "x", "type": "int"}
+	]}]}
+]}`
+
+// The reader renames every named type. The aliases are a mix of names relative to the namespace
+// of the type they're declared on, and fully qualified names.
+const aliasReaderSchema = `{"type": "record", "name": "New", "namespace": "com.example", "aliases": ["Old"], "fields": [
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "aliases": ["com.example.OldKind"], "symbols": ["A", "B"]}},
+	{"name": "hash", "type": {"type": "fixed", "name": "Hash", "namespace": "other", "aliases": ["OldHash"], "size": 2}},
+	{"name": "child", "type": ["null", {"type": "record", "name": "Child", "aliases": ["OldChild"], "fields": [
+		{"name": "x", "type": "long"}
+	]}]}
+]}`
+
+func TestCompileAliases(t *testing.T) {
+	d, err := generic.NewDecoderSchemaBytes([]byte(aliasWriterSchema), []byte(aliasReaderSchema))
+	assert.Nil(t, err)
+	e, err := generic.NewEncoderSchemaBytes([]byte(aliasWriterSchema))
+	assert.Nil(t, err)
+
+	buf, err := e.AppendEncoded(nil, map[string]interface{}{
+		"kind":  "B",
+		"hash":  []byte("hi"),
+		"child": map[string]interface{}{"x": 3},
+	})
+	assert.Nil(t, err)
+	record, _, err := d.DecodeRecordBytes(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "com.example.New", record.Def.AvroName().String())
+	assert.Equal(t, map[string]interface{}{
+		"kind":  "B",
+		"hash":  []byte("hi"),
+		"child": map[string]interface{}{"x": int64(3)},
+	}, record.Map())
+}
+
+func TestCompileAliasFullName(t *testing.T) {
+	// The alias is relative to the namespace in the reader's full name, not the enclosing namespace
+	_, err := compiler.CompileSchemaBytes(
+		[]byte(`{"type": "record", "name": "com.example.Old", "fields": []}`),
+		[]byte(`{"type": "record", "name": "com.example.New", "aliases": ["Old"], "fields": []}`),
+	)
+	assert.Nil(t, err)
+}
+
+func TestCompileAliasMismatch(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		reader string
+	}{
+		{"no alias", `{"type": "record", "name": "com.example.New", "fields": []}`},
+		// A relative alias is in the namespace of the type it's declared on, so this is other.Old
+		{"wrong namespace", `{"type": "record", "name": "other.New", "aliases": ["Old"], "fields": []}`},
+		// Aliases in the writer aren't used
+		{"writer alias", `{"type": "record", "name": "com.example.Older", "fields": []}`},
+	} {
+		_, err := compiler.CompileSchemaBytes(
+			[]byte(`{"type": "record", "name": "com.example.Old", "aliases": ["Older"], "fields": []}`),
+			[]byte(c.reader),
+		)
+		assert.NotNil(t, err, c.name)
+	}
+
+	// A renamed fixed must still be the same size
+	_, err := compiler.CompileSchemaBytes(
+		[]byte(`{"type": "fixed", "name": "Old", "size": 2}`),
+		[]byte(`{"type": "fixed", "name": "New", "aliases": ["Old"], "size": 3}`),
+	)
+	assert.NotNil(t, err)
+}
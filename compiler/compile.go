@@ -24,6 +24,16 @@ func CompileSchemaBytes(writer, reader []byte) (*vm.Program, error) {
 	return Compile(writerType, readerType)
 }
 
+// CompileOptimizedSchemaBytes compiles the schemas like CompileSchemaBytes and returns the program after running
+// Optimize on it. The generated code builds its programs with it.
+func CompileOptimizedSchemaBytes(writer, reader []byte) (*vm.Program, error) {
+	program, err := CompileSchemaBytes(writer, reader)
+	if err != nil {
+		return nil, err
+	}
+	return Optimize(program)
+}
+
 func parseSchema(s []byte) (schema.AvroType, error) {
 	ns := schema.NewNamespace(false)
 	sType, err := ns.TypeForSchema(s)
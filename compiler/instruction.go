@@ -32,6 +32,8 @@ func (b *literalIRInstruction) CompileToVM(_ *irProgram) ([]vm.Instruction, erro
 
 type methodCallIRInstruction struct {
 	method string
+	// The name of the record read by the method, which names the Call instruction
+	record string
 }
 
 func (b *methodCallIRInstruction) VMLength() int {
@@ -47,11 +49,13 @@ func (b *methodCallIRInstruction) CompileToVM(p *irProgram) ([]vm.Instruction, e
 	if !ok {
 		return nil, fmt.Errorf("Unable to call unknown method %q", b.method)
 	}
-	return []vm.Instruction{vm.Instruction{vm.Call, method.offset, b.Name()}}, nil
+	return []vm.Instruction{vm.Instruction{vm.Call, method.offset, b.record}}, nil
 }
 
 type blockStartIRInstruction struct {
 	blockId int
+	// Set if the reader doesn't have the array or map, so blocks with a byte size are skipped over
+	skip bool
 }
 
 func (b *blockStartIRInstruction) VMLength() int {
@@ -62,14 +66,28 @@ func (b *blockStartIRInstruction) Name() string {
 	return "Block start"
 }
 
-// At the beginning of a block, read the length into the Long register
+// At the beginning of a block, read the length into the Long register (counting it towards the item limit)
 // If the block length is 0, jump past the block body because we're done
 // If the block length is negative, read the byte count, throw it away, multiply the length by -1
 // Once we've figured out the number of iterations, push the loop length onto the loop stack
+// If the block is being skipped, a negative length means the whole block can be skipped using the byte count,
+// and then we go back to the top to read the next block
 func (b *blockStartIRInstruction) CompileToVM(p *irProgram) ([]vm.Instruction, error) {
 	block := p.blocks[b.blockId]
+	if b.skip {
+		return []vm.Instruction{
+			vm.Instruction{vm.ReadCount, vm.NoopField, b.Name()},
+			vm.Instruction{vm.EvalEqual, 0, b.Name()},
+			vm.Instruction{vm.CondJump, block.end + 5, b.Name()},
+			vm.Instruction{vm.EvalGreater, 0, b.Name()},
+			vm.Instruction{vm.CondJump, block.start + 7, b.Name()},
+			vm.Instruction{vm.SkipBlock, vm.NoopField, b.Name()},
+			vm.Instruction{vm.Jump, block.start, b.Name()},
+			vm.Instruction{vm.PushLoop, 0, b.Name()},
+		}, nil
+	}
 	return []vm.Instruction{
-		vm.Instruction{vm.Read, vm.Long, b.Name()},
+		vm.Instruction{vm.ReadCount, vm.NoopField, b.Name()},
 		vm.Instruction{vm.EvalEqual, 0, b.Name()},
 		vm.Instruction{vm.CondJump, block.end + 5, b.Name()},
 		vm.Instruction{vm.EvalGreater, 0, b.Name()},
@@ -177,3 +195,38 @@ func (s *switchEndIRInstruction) Name() string {
 func (s *switchEndIRInstruction) CompileToVM(p *irProgram) ([]vm.Instruction, error) {
 	return []vm.Instruction{}, nil
 }
+
+// skipFieldIRInstruction marks the start of a writer field the reader doesn't have.
+// It compiles to nothing, the offset is recorded in the program's SkippedFields.
+type skipFieldIRInstruction struct {
+	skipId int
+}
+
+func (s *skipFieldIRInstruction) VMLength() int {
+	return 0
+}
+
+func (s *skipFieldIRInstruction) Name() string {
+	return "Skip field"
+}
+
+func (s *skipFieldIRInstruction) CompileToVM(p *irProgram) ([]vm.Instruction, error) {
+	return []vm.Instruction{}, nil
+}
+
+// skipFieldEndIRInstruction marks the end of the record containing a skipped field
+type skipFieldEndIRInstruction struct {
+	skipId int
+}
+
+func (s *skipFieldEndIRInstruction) VMLength() int {
+	return 0
+}
+
+func (s *skipFieldEndIRInstruction) Name() string {
+	return "Skip field end"
+}
+
+func (s *skipFieldEndIRInstruction) CompileToVM(p *irProgram) ([]vm.Instruction, error) {
+	return []vm.Instruction{}, nil
+}
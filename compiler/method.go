@@ -26,14 +26,14 @@ func (p *irMethod) addLiteral(op vm.Op, operand int, name string) {
 	p.body = append(p.body, &literalIRInstruction{vm.Instruction{op, operand, name}, name})
 }
 
-func (p *irMethod) addMethodCall(method string) {
-	p.body = append(p.body, &methodCallIRInstruction{method})
+func (p *irMethod) addMethodCall(method, record string) {
+	p.body = append(p.body, &methodCallIRInstruction{method, record})
 }
 
-func (p *irMethod) addBlockStart() int {
+func (p *irMethod) addBlockStart(skip bool) int {
 	id := len(p.program.blocks)
 	p.program.blocks = append(p.program.blocks, &irBlock{})
-	p.body = append(p.body, &blockStartIRInstruction{id})
+	p.body = append(p.body, &blockStartIRInstruction{id, skip})
 	return id
 }
 
@@ -56,6 +56,17 @@ func (p *irMethod) addSwitchEnd(id int) {
 	p.body = append(p.body, &switchEndIRInstruction{id})
 }
 
+func (p *irMethod) addSkipField(index int, name string) int {
+	id := len(p.program.skipped)
+	p.program.skipped = append(p.program.skipped, &vm.SkippedField{Index: index, Name: name})
+	p.body = append(p.body, &skipFieldIRInstruction{id})
+	return id
+}
+
+func (p *irMethod) addSkipFieldEnd(id int) {
+	p.body = append(p.body, &skipFieldEndIRInstruction{id})
+}
+
 func (p *irMethod) addError(msg string) int {
 	id := len(p.program.errors) + 1
 	p.program.errors = append(p.program.errors, msg)
@@ -80,7 +91,8 @@ func (p *irMethod) compileType(writer, reader schema.AvroType) error {
 				if writer.IsReadableBy(r) {
 					p.addLiteral(vm.SetLong, readerIndex, name)
 					p.addLiteral(vm.Set, vm.Long, name)
-					p.addLiteral(vm.Enter, readerIndex, name)
+					// Union branches aren't part of the field path, so the Enter is unnamed
+					p.addLiteral(vm.Enter, readerIndex, "")
 					err := p.compileType(writer, r)
 					if err != nil {
 						return err
@@ -111,57 +123,79 @@ func (p *irMethod) compileType(writer, reader schema.AvroType) error {
 		return fmt.Errorf("Incompatible types: %v %v", reader, writer)
 	case *schema.UnionField:
 		return p.compileUnion(v, reader)
-	case *schema.IntField:
-		p.addLiteral(vm.Read, vm.Int, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Int, name)
+	case *schema.IntField, *schema.LongField, *schema.FloatField, *schema.DoubleField,
+		*schema.StringField, *schema.BytesField, *schema.BoolField:
+		return p.compilePrimitive(writer, reader)
+	case *schema.NullField:
+		if _, ok := reader.(*schema.NullField); ok || reader == nil {
+			return nil
 		}
-		return nil
+		return fmt.Errorf("Incompatible types: %v %v", reader, writer)
+	}
+	return fmt.Errorf("Unsupported type: %T", writer)
+}
+
+// promotions are the conversions for each writer and reader primitive type allowed by the spec
+var promotions = map[[2]int]vm.Op{
+	{vm.Int, vm.Long}:     vm.IntToLong,
+	{vm.Int, vm.Float}:    vm.IntToFloat,
+	{vm.Int, vm.Double}:   vm.IntToDouble,
+	{vm.Long, vm.Float}:   vm.LongToFloat,
+	{vm.Long, vm.Double}:  vm.LongToDouble,
+	{vm.Float, vm.Double}: vm.FloatToDouble,
+	{vm.String, vm.Bytes}: vm.StringToBytes,
+	{vm.Bytes, vm.String}: vm.BytesToString,
+}
+
+// primitiveType returns the Read and Set operand for a primitive type, or Unused if t isn't one
+func primitiveType(t schema.AvroType) int {
+	switch t.(type) {
+	case *schema.BoolField:
+		return vm.Boolean
+	case *schema.IntField:
+		return vm.Int
 	case *schema.LongField:
-		p.addLiteral(vm.Read, vm.Long, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Long, name)
-		}
-		return nil
-	case *schema.StringField:
-		p.addLiteral(vm.Read, vm.String, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.String, name)
-		}
-		return nil
-	case *schema.BytesField:
-		p.addLiteral(vm.Read, vm.Bytes, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Bytes, name)
-		}
-		return nil
+		return vm.Long
 	case *schema.FloatField:
-		p.addLiteral(vm.Read, vm.Float, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Float, name)
-		}
-		return nil
+		return vm.Float
 	case *schema.DoubleField:
-		p.addLiteral(vm.Read, vm.Double, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Double, name)
-		}
-		return nil
-	case *schema.BoolField:
-		p.addLiteral(vm.Read, vm.Boolean, name)
-		if reader != nil {
-			p.addLiteral(vm.Set, vm.Boolean, name)
-		}
-		return nil
-	case *schema.NullField:
+		return vm.Double
+	case *schema.BytesField:
+		return vm.Bytes
+	case *schema.StringField:
+		return vm.String
+	}
+	return vm.Unused
+}
+
+// compilePrimitive reads a primitive value and sets the reader to it, converting it first if the reader
+// has a different type the writer's type can be promoted to. If there's no reader the value is skipped.
+func (p *irMethod) compilePrimitive(writer, reader schema.AvroType) error {
+	log("compilePrimitive()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
+	name := writer.Name()
+	writerType := primitiveType(writer)
+	if reader == nil {
+		p.addLiteral(vm.Skip, writerType, name)
 		return nil
 	}
-	return fmt.Errorf("Unsupported type: %t", writer)
+
+	readerType := primitiveType(reader)
+	conversion, promoted := promotions[[2]int{writerType, readerType}]
+	if readerType != writerType && !promoted {
+		return fmt.Errorf("Incompatible types: %v %v", reader, writer)
+	}
+	p.addLiteral(vm.Read, writerType, name)
+	if promoted {
+		p.addLiteral(conversion, vm.NoopField, name)
+	}
+	p.addLiteral(vm.Set, readerType, name)
+	return nil
 }
 
 func (p *irMethod) compileRef(writer, reader *schema.Reference) error {
 	log("compileRef()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
-	if reader != nil && writer.TypeName != reader.TypeName {
+	// The reader can have a different name to the writer if one of its aliases is the writer's name
+	if reader != nil && !writer.IsReadableBy(reader) {
 		return fmt.Errorf("Incompatible types by name: %v %v", reader, writer)
 	}
 
@@ -170,11 +204,13 @@ func (p *irMethod) compileRef(writer, reader *schema.Reference) error {
 		var readerDef *schema.RecordDefinition
 		var ok bool
 		recordMethodName := fmt.Sprintf("record-r-%v", writer.Def.AvroName().String())
+		recordName := writer.Def.AvroName().Name
 		if reader != nil {
 			if readerDef, ok = reader.Def.(*schema.RecordDefinition); !ok {
 				return fmt.Errorf("Incompatible types: %v %v", reader, writer)
 			}
-			recordMethodName = fmt.Sprintf("record-rw-%v", writer.Def.AvroName().String())
+			recordMethodName = fmt.Sprintf("record-rw-%v-%v", writer.Def.AvroName().String(), reader.Def.AvroName().String())
+			recordName = reader.Def.AvroName().Name
 		}
 
 		if _, ok := p.program.methods[recordMethodName]; !ok {
@@ -184,7 +220,7 @@ func (p *irMethod) compileRef(writer, reader *schema.Reference) error {
 				return err
 			}
 		}
-		p.addMethodCall(recordMethodName)
+		p.addMethodCall(recordMethodName, recordName)
 		return nil
 	case *schema.FixedDefinition:
 		var readerDef *schema.FixedDefinition
@@ -205,18 +241,20 @@ func (p *irMethod) compileRef(writer, reader *schema.Reference) error {
 		}
 		return p.compileEnum(writer.Def.(*schema.EnumDefinition), readerDef)
 	}
-	return fmt.Errorf("Unsupported reference type %t", reader)
+	return fmt.Errorf("Unsupported reference type %T", writer.Def)
 }
 
 func (p *irMethod) compileMap(writer, reader *schema.MapField) error {
 	log("compileMap()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
-	blockId := p.addBlockStart()
+	blockId := p.addBlockStart(reader == nil)
 	name := writer.Name()
-	p.addLiteral(vm.Read, vm.String, name)
 	var readerType schema.AvroType
 	if reader != nil {
+		p.addLiteral(vm.Read, vm.String, name)
 		p.addLiteral(vm.AppendMap, vm.Unused, name)
 		readerType = reader.ItemType()
+	} else {
+		p.addLiteral(vm.Skip, vm.String, name)
 	}
 	err := p.compileType(writer.ItemType(), readerType)
 	if err != nil {
@@ -231,7 +269,7 @@ func (p *irMethod) compileMap(writer, reader *schema.MapField) error {
 
 func (p *irMethod) compileArray(writer, reader *schema.ArrayField) error {
 	log("compileArray()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
-	blockId := p.addBlockStart()
+	blockId := p.addBlockStart(reader == nil)
 	name := writer.Name()
 	var readerType schema.AvroType
 	if reader != nil {
@@ -259,11 +297,12 @@ func (p *irMethod) compileRecord(writer, reader *schema.RecordDefinition) error
 				if !field.HasDefault() {
 					return fmt.Errorf("Incompatible schemas: field %v in reader is not present in writer and has no default value", field.Name())
 				}
-				p.addLiteral(vm.SetDefault, field.Index(), name)
+				p.addLiteral(vm.SetDefault, field.Index(), field.Name())
 			}
 		}
 	}
 
+	var skipped []int
 	for _, field := range writer.Fields() {
 		var readerType schema.AvroType
 		var readerField *schema.Field
@@ -271,7 +310,10 @@ func (p *irMethod) compileRecord(writer, reader *schema.RecordDefinition) error
 			readerField = reader.GetReaderField(field)
 			if readerField != nil {
 				readerType = readerField.Type()
-				p.addLiteral(vm.Enter, readerField.Index(), name)
+				// Enter instructions are named after the field, which the VM uses to build the path in errors
+				p.addLiteral(vm.Enter, readerField.Index(), readerField.Name())
+			} else {
+				skipped = append(skipped, p.addSkipField(field.Index(), field.Name()))
 			}
 		}
 		err := p.compileType(field.Type(), readerType)
@@ -282,26 +324,33 @@ func (p *irMethod) compileRecord(writer, reader *schema.RecordDefinition) error
 			p.addLiteral(vm.Exit, vm.NoopField, name)
 		}
 	}
+	for _, id := range skipped {
+		p.addSkipFieldEnd(id)
+	}
 	return nil
 }
 
 func (p *irMethod) compileEnum(writer, reader *schema.EnumDefinition) error {
 	log("compileEnum()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
 	name := writer.Name()
-	p.addLiteral(vm.Read, vm.Int, name)
-	if reader != nil {
-		p.addLiteral(vm.Set, vm.Int, name)
+	if reader == nil {
+		p.addLiteral(vm.Skip, vm.Int, name)
+		return nil
 	}
+	p.addLiteral(vm.Read, vm.Int, name)
+	p.addLiteral(vm.Set, vm.Int, name)
 	return nil
 }
 
 func (p *irMethod) compileFixed(writer, reader *schema.FixedDefinition) error {
 	log("compileFixed()\n writer:\n %v\n---\nreader: %v\n---\n", writer, reader)
 	name := writer.Name()
-	p.addLiteral(vm.Read, 11+writer.SizeBytes(), name)
-	if reader != nil {
-		p.addLiteral(vm.Set, vm.Bytes, name)
+	if reader == nil {
+		p.addLiteral(vm.Skip, 11+writer.SizeBytes(), name)
+		return nil
 	}
+	p.addLiteral(vm.Read, 11+writer.SizeBytes(), name)
+	p.addLiteral(vm.Set, vm.Bytes, name)
 	return nil
 }
 
@@ -325,7 +374,8 @@ writer:
 			for readerIndex, r := range unionReader.AvroTypes() {
 				if t.IsReadableBy(r) {
 					p.addSwitchCase(switchId, i, readerIndex)
-					p.addLiteral(vm.Enter, readerIndex, name)
+					// Union branches aren't part of the field path, so the Enter is unnamed
+					p.addLiteral(vm.Enter, readerIndex, "")
 					err := p.compileType(t, r)
 					if err != nil {
 						return err
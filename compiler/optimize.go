@@ -0,0 +1,540 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/clear-street/gogen-avro/vm"
+)
+
+// Methods with at most this many instructions, which don't call other methods, are inlined
+const maxInlineLength = 32
+
+// optNode is an instruction in a program being optimized. Jump, CondJump and Call instructions
+// point at their target node instead of an offset, so nodes can be inserted and removed freely.
+type optNode struct {
+	inst    vm.Instruction
+	target  *optNode
+	deleted bool
+	// Set for instructions in main, which are laid out before the first method
+	main bool
+}
+
+// optSkipped is an entry in the program's SkippedFields, with its range held as the nodes at each end.
+// A nil end is the end of the program.
+type optSkipped struct {
+	start, end *optNode
+	field      vm.SkippedField
+}
+
+type optimizer struct {
+	nodes   []*optNode
+	skipped []*optSkipped
+}
+
+// Optimize returns an optimized copy of a program produced by Compile. It runs these passes:
+//
+//   - small methods which don't call other methods are inlined, except for the calls made from main
+//   - Read instructions followed by a Set of the same type are fused into a ReadSet
+//   - reads of values which are never used become Skip instructions, and runs of fixed-size skips are merged into one
+//   - an Enter, ReadSet and Exit of a field holding a single value are fused into a ReadSetField
+//   - the thirteen instructions which start and end each array or map block become a BlockStart
+//     or SkipBlockStart and a BlockEnd
+//   - jumps to the next instruction and unreachable code are removed
+//
+// The optimized program decodes the same data into the same calls on the target, and reports the same fields
+// to an Observer. An inlined method is wrapped in a PushDepth and PopDepth, so it still counts towards MaxDepth
+// like the Call it replaces.
+//
+// Optimize relies on the patterns the compiler generates, like registers being used by the instruction
+// right after the one which sets them. It shouldn't be used on programs built some other way.
+func Optimize(program *vm.Program) (*vm.Program, error) {
+	o, err := newOptimizer(program)
+	if err != nil {
+		return nil, err
+	}
+	for o.inline() {
+	}
+	o.fuseReads()
+	o.mergeSkips()
+	o.fuseFields()
+	o.fuseBlocks()
+	for o.removeJumps() || o.removeUnreachable() {
+	}
+	return o.program(program.Errors), nil
+}
+
+func newOptimizer(program *vm.Program) (*optimizer, error) {
+	o := &optimizer{nodes: make([]*optNode, len(program.Instructions))}
+	mainEnd := len(program.Instructions)
+	for i, inst := range program.Instructions {
+		o.nodes[i] = &optNode{inst: inst}
+		if inst.Op == vm.Call && inst.Operand < mainEnd {
+			mainEnd = inst.Operand
+		}
+	}
+	for i, n := range o.nodes {
+		n.main = i < mainEnd
+		if isJump(n.inst.Op) {
+			if n.inst.Operand < 0 || n.inst.Operand >= len(o.nodes) {
+				return nil, fmt.Errorf("Instruction %v: %v target out of range", i, n.inst)
+			}
+			n.target = o.nodes[n.inst.Operand]
+		}
+	}
+	for _, f := range program.SkippedFields {
+		if f.Start < 0 || f.Start > f.End || f.End > len(o.nodes) {
+			return nil, fmt.Errorf("Skipped field %v range %v - %v out of range", f.Name, f.Start, f.End)
+		}
+		s := &optSkipped{field: f}
+		if f.Start < len(o.nodes) {
+			s.start = o.nodes[f.Start]
+		}
+		if f.End < len(o.nodes) {
+			s.end = o.nodes[f.End]
+		}
+		o.skipped = append(o.skipped, s)
+	}
+	return o, nil
+}
+
+func isJump(op vm.Op) bool {
+	switch op {
+	case vm.Jump, vm.CondJump, vm.Call, vm.BlockStart, vm.SkipBlockStart, vm.BlockEnd:
+		return true
+	}
+	return false
+}
+
+// compact drops the deleted nodes, first pointing every jump and skipped field at the next live node after it
+func (o *optimizer) compact() {
+	next := make(map[*optNode]*optNode)
+	var following *optNode
+	for i := len(o.nodes) - 1; i >= 0; i-- {
+		n := o.nodes[i]
+		if !n.deleted {
+			following = n
+		}
+		next[n] = following
+	}
+
+	live := make([]*optNode, 0, len(o.nodes))
+	for _, n := range o.nodes {
+		if n.deleted {
+			continue
+		}
+		if n.target != nil {
+			n.target = next[n.target]
+		}
+		live = append(live, n)
+	}
+	o.nodes = live
+	for _, s := range o.skipped {
+		if s.start != nil {
+			s.start = next[s.start]
+		}
+		if s.end != nil {
+			s.end = next[s.end]
+		}
+	}
+}
+
+// inline replaces calls to small leaf methods with a copy of the method body.
+// It returns true if any calls were inlined, since that may turn their callers into leaves.
+func (o *optimizer) inline() bool {
+	bodies := make(map[*optNode][]*optNode)
+	changed := false
+	result := make([]*optNode, 0, len(o.nodes))
+	for _, n := range o.nodes {
+		if n.inst.Op != vm.Call || n.main {
+			result = append(result, n)
+			continue
+		}
+		body, ok := bodies[n.target]
+		if !ok {
+			body = o.methodBody(n.target)
+			bodies[n.target] = body
+		}
+		if body == nil {
+			result = append(result, n)
+			continue
+		}
+
+		// The call node becomes a PushDepth before the copy, so jumps to the call still land on it
+		copies := make(map[*optNode]*optNode, len(body))
+		inlined := make([]*optNode, len(body))
+		for i, b := range body {
+			inlined[i] = &optNode{}
+			copies[b] = inlined[i]
+		}
+		n.inst = vm.Instruction{Op: vm.PushDepth, Operand: vm.NoopField}
+		n.target = nil
+		end := &optNode{inst: vm.Instruction{Op: vm.PopDepth, Operand: vm.NoopField}}
+		for i, b := range body {
+			inlined[i].inst = b.inst
+			inlined[i].target = nil
+			if b.target != nil {
+				if t, ok := copies[b.target]; ok {
+					inlined[i].target = t
+				} else {
+					// The only other target allowed by methodBody is the Return
+					inlined[i].target = end
+				}
+			}
+		}
+		// Jumps to the Return land on the PopDepth which takes its place
+		result = append(result, n)
+		result = append(result, inlined...)
+		result = append(result, end)
+		o.copySkipped(copies, end)
+		changed = true
+	}
+	o.nodes = result
+	o.compact()
+	return changed
+}
+
+// copySkipped adds the skipped fields of an inlined method body for the copy of it.
+// A field whose range ends at the Return ends at end instead.
+func (o *optimizer) copySkipped(copies map[*optNode]*optNode, end *optNode) {
+	for _, s := range o.skipped {
+		start, ok := copies[s.start]
+		if !ok {
+			continue
+		}
+		c := &optSkipped{start: start, end: end, field: s.field}
+		if e, ok := copies[s.end]; ok {
+			c.end = e
+		}
+		o.skipped = append(o.skipped, c)
+	}
+}
+
+// methodBody returns the instructions of the method starting at start, up to but not including
+// the Return, if the method can be inlined. Otherwise it returns nil.
+func (o *optimizer) methodBody(start *optNode) []*optNode {
+	first := -1
+	for i, n := range o.nodes {
+		if n == start {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	end := first
+	for ; end < len(o.nodes) && o.nodes[end].inst.Op != vm.Return; end++ {
+		inst := o.nodes[end].inst
+		// A Halt(0) would return from the caller instead of ending the program
+		if end-first >= maxInlineLength || inst.Op == vm.Call || (inst.Op == vm.Halt && inst.Operand == 0) {
+			return nil
+		}
+	}
+	// An empty method has no instruction to take the place of the call
+	if end == first || end == len(o.nodes) {
+		return nil
+	}
+
+	body := o.nodes[first:end]
+	inBody := make(map[*optNode]bool, len(body)+1)
+	for _, n := range o.nodes[first : end+1] {
+		inBody[n] = true
+	}
+	for _, n := range body {
+		if n.target != nil && !inBody[n.target] {
+			return nil
+		}
+	}
+	return body
+}
+
+// jumpTargets returns the set of instructions which are the target of a jump or call
+func (o *optimizer) jumpTargets() map[*optNode]bool {
+	targets := make(map[*optNode]bool)
+	for _, n := range o.nodes {
+		if n.target != nil {
+			targets[n.target] = true
+		}
+	}
+	return targets
+}
+
+// usesRegister returns whether an instruction reads the register set by the instruction before it,
+// or might do so after jumping
+func usesRegister(op vm.Op) bool {
+	switch op {
+	case vm.Set, vm.AppendMap, vm.EvalEqual, vm.EvalGreater, vm.AddLong, vm.MultLong, vm.PushLoop, vm.Jump, vm.CondJump,
+		vm.IntToLong, vm.IntToFloat, vm.IntToDouble, vm.LongToFloat, vm.LongToDouble, vm.FloatToDouble, vm.StringToBytes, vm.BytesToString:
+		return true
+	}
+	return false
+}
+
+// setType returns the operand of the Set which assigns a value read with the given Read operand
+func setType(readType int) int {
+	if readType > vm.UnusedLong {
+		return vm.Bytes
+	}
+	return readType
+}
+
+// fuseReads turns Read instructions into a ReadSet if they're followed by a matching Set,
+// and into a Skip if the value is never used. Skips of fixed-size values are changed to skip the bytes,
+// so they can be merged.
+func (o *optimizer) fuseReads() {
+	targets := o.jumpTargets()
+	for i, n := range o.nodes {
+		// Reading an UnusedLong already discards the value
+		if n.inst.Op == vm.Read && n.inst.Operand != vm.UnusedLong && i+1 < len(o.nodes) {
+			next := o.nodes[i+1]
+			if next.inst.Op == vm.Set && next.inst.Operand == setType(n.inst.Operand) && !targets[next] {
+				n.inst.Op = vm.ReadSet
+				next.deleted = true
+			} else if !usesRegister(next.inst.Op) {
+				n.inst.Op = vm.Skip
+			}
+		}
+
+		if n.inst.Op != vm.Skip {
+			continue
+		}
+		switch n.inst.Operand {
+		case vm.Null:
+			n.deleted = true
+		case vm.Boolean:
+			n.inst.Operand = 11 + 1
+		case vm.Float:
+			n.inst.Operand = 11 + 4
+		case vm.Double:
+			n.inst.Operand = 11 + 8
+		}
+	}
+	o.compact()
+}
+
+// fuseFields turns an Enter, ReadSet and Exit of a value into a ReadSetField, which doesn't push a frame
+func (o *optimizer) fuseFields() {
+	targets := o.jumpTargets()
+	for i := 0; i+2 < len(o.nodes); i++ {
+		enter, read, exit := o.nodes[i], o.nodes[i+1], o.nodes[i+2]
+		if enter.inst.Op != vm.Enter || read.inst.Op != vm.ReadSet || exit.inst.Op != vm.Exit || targets[read] || targets[exit] {
+			continue
+		}
+		if read.inst.Operand < vm.Null || read.inst.Operand > vm.String {
+			continue
+		}
+		enter.inst.Op = vm.ReadSetField
+		enter.inst.Operand = vm.FieldOperand(enter.inst.Operand, read.inst.Operand)
+		read.deleted = true
+		exit.deleted = true
+		i += 2
+	}
+	o.compact()
+}
+
+// blockStart returns whether nodes start with the instructions the compiler emits at the start of a block,
+// and whether the block is skipped
+func blockStart(nodes []*optNode) (bool, bool) {
+	if len(nodes) < 8 {
+		return false, false
+	}
+	matches := func(n *optNode, op vm.Op, operand int) bool {
+		return n.inst.Op == op && (isJump(op) || n.inst.Operand == operand)
+	}
+	if !matches(nodes[0], vm.ReadCount, vm.NoopField) || !matches(nodes[1], vm.EvalEqual, 0) || !matches(nodes[2], vm.CondJump, 0) ||
+		!matches(nodes[3], vm.EvalGreater, 0) || !matches(nodes[4], vm.CondJump, 0) || nodes[4].target != nodes[7] ||
+		!matches(nodes[7], vm.PushLoop, 0) {
+		return false, false
+	}
+	if matches(nodes[5], vm.Read, vm.UnusedLong) && matches(nodes[6], vm.MultLong, -1) {
+		return true, false
+	}
+	if matches(nodes[5], vm.SkipBlock, vm.NoopField) && matches(nodes[6], vm.Jump, 0) && nodes[6].target == nodes[0] {
+		return true, true
+	}
+	return false, false
+}
+
+// blockEnd returns whether nodes start with the instructions the compiler emits at the end of the block
+// which starts at start
+func blockEnd(nodes []*optNode, start []*optNode) bool {
+	return len(nodes) >= 5 && nodes[0].inst.Op == vm.PopLoop &&
+		nodes[1].inst.Op == vm.AddLong && nodes[1].inst.Operand == -1 &&
+		nodes[2].inst.Op == vm.EvalEqual && nodes[2].inst.Operand == 0 &&
+		nodes[3].inst.Op == vm.CondJump && nodes[3].target == start[0] &&
+		nodes[4].inst.Op == vm.Jump && nodes[4].target == start[7]
+}
+
+// fuseBlocks replaces the instructions at the start and end of each array or map block with a BlockStart
+// or SkipBlockStart and a BlockEnd. The first instruction of each is kept, so jumps to them still land on the block.
+func (o *optimizer) fuseBlocks() {
+	index := make(map[*optNode]int, len(o.nodes))
+	for i, n := range o.nodes {
+		index[n] = i
+	}
+	// The number of jumps to each instruction, which mustn't land in the middle of the instructions being replaced
+	targets := make(map[*optNode]int)
+	for _, n := range o.nodes {
+		if n.target != nil {
+			targets[n.target]++
+		}
+	}
+
+	for i, n := range o.nodes {
+		ok, skip := blockStart(o.nodes[i:])
+		if !ok {
+			continue
+		}
+		start := o.nodes[i : i+8]
+		end, found := index[start[2].target]
+		if !found || end-5 <= i+7 || !blockEnd(o.nodes[end-5:], start) {
+			continue
+		}
+		finish := o.nodes[end-5 : end]
+
+		// The instructions which are replaced. Only the PushLoop may be jumped to, by the start and the end of the block.
+		replaced := make([]*optNode, 0, 11)
+		replaced = append(append(replaced, start[1:]...), finish[1:]...)
+		for _, m := range replaced {
+			if m != start[7] && targets[m] != 0 {
+				ok = false
+			}
+		}
+		if targets[start[7]] != 2 || !ok {
+			continue
+		}
+
+		n.inst = vm.Instruction{Op: vm.BlockStart, Operand: 0, Name: n.inst.Name}
+		n.target = start[2].target
+		if skip {
+			n.inst.Op = vm.SkipBlockStart
+		}
+		finish[0].inst = vm.Instruction{Op: vm.BlockEnd, Operand: 0, Name: finish[0].inst.Name}
+		finish[0].target = n
+		for _, m := range replaced {
+			m.deleted = true
+			m.target = nil
+		}
+	}
+	o.compact()
+}
+
+func isFixedSkip(inst vm.Instruction) bool {
+	return inst.Op == vm.Skip && inst.Operand > vm.UnusedLong
+}
+
+// mergeSkips combines consecutive fixed-size skips into one
+func (o *optimizer) mergeSkips() {
+	targets := o.jumpTargets()
+	var run *optNode
+	for _, n := range o.nodes {
+		if !isFixedSkip(n.inst) {
+			run = nil
+			continue
+		}
+		if run != nil && !targets[n] {
+			run.inst.Operand += n.inst.Operand - 11
+			n.deleted = true
+			continue
+		}
+		run = n
+	}
+	o.compact()
+}
+
+// removeJumps deletes jumps to the instruction right after them
+func (o *optimizer) removeJumps() bool {
+	changed := false
+	for i, n := range o.nodes {
+		if (n.inst.Op == vm.Jump || n.inst.Op == vm.CondJump) && i+1 < len(o.nodes) && n.target == o.nodes[i+1] {
+			n.deleted = true
+			changed = true
+		}
+	}
+	o.compact()
+	return changed
+}
+
+// removeUnreachable deletes the instructions which can't be reached from the start of the program
+func (o *optimizer) removeUnreachable() bool {
+	if len(o.nodes) == 0 {
+		return false
+	}
+	index := make(map[*optNode]int, len(o.nodes))
+	for i, n := range o.nodes {
+		index[n] = i
+	}
+
+	reachable := make([]bool, len(o.nodes))
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(o.nodes) || reachable[i] {
+			continue
+		}
+		reachable[i] = true
+		n := o.nodes[i]
+		if n.target != nil {
+			stack = append(stack, index[n.target])
+		}
+		// After an Enter, AppendArray, AppendMap or PushLoop the VM runs the body and continues after
+		// the matching Exit or PopLoop, and after a Call it continues with the next instruction
+		switch n.inst.Op {
+		case vm.Jump, vm.Return, vm.Halt, vm.BlockEnd:
+		default:
+			stack = append(stack, i+1)
+		}
+	}
+
+	changed := false
+	for i, n := range o.nodes {
+		if !reachable[i] {
+			n.deleted = true
+			changed = true
+		}
+	}
+	// The skipped fields of removed methods would otherwise move on to the code after them
+	skipped := o.skipped[:0]
+	for _, s := range o.skipped {
+		if s.start == nil || !s.start.deleted {
+			skipped = append(skipped, s)
+		}
+	}
+	o.skipped = skipped
+	o.compact()
+	return changed
+}
+
+// program lays out the remaining instructions and resolves the jump targets to offsets
+func (o *optimizer) program(errors []string) *vm.Program {
+	index := make(map[*optNode]int, len(o.nodes))
+	for i, n := range o.nodes {
+		index[n] = i
+	}
+	instructions := make([]vm.Instruction, len(o.nodes))
+	for i, n := range o.nodes {
+		instructions[i] = n.inst
+		if n.target != nil {
+			instructions[i].Operand = index[n.target]
+		}
+	}
+	var skipped []vm.SkippedField
+	for _, s := range o.skipped {
+		f := s.field
+		f.Start, f.End = len(o.nodes), len(o.nodes)
+		if s.start != nil {
+			f.Start = index[s.start]
+		}
+		if s.end != nil {
+			f.End = index[s.end]
+		}
+		skipped = append(skipped, f)
+	}
+	return &vm.Program{
+		Instructions:  instructions,
+		Errors:        append([]string{}, errors...),
+		SkippedFields: skipped,
+	}
+}
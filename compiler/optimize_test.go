@@ -0,0 +1,337 @@
+package compiler_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/generic"
+	"github.com/clear-street/gogen-avro/schema"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Nested arrays, maps and recursive records are cut off below this depth in sample values
+const sampleDepth = 3
+
+type testSchema struct {
+	name string
+	t    schema.AvroType
+}
+
+// loadSchemas parses the schemas used by the generated code tests, skipping the ones which are split across files
+func loadSchemas(t testing.TB) map[string][]testSchema {
+	files, err := filepath.Glob("../test/*/*.avsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := make(map[string][]testSchema)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := generic.ParseSchema(b)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Base(filepath.Dir(f))
+		schemas[dir] = append(schemas[dir], testSchema{name: filepath.Join(dir, filepath.Base(f)), t: s})
+	}
+	return schemas
+}
+
+// sampleValue builds a value for t which the generic package can encode, using r to pick union branches
+func sampleValue(t schema.AvroType, r *rand.Rand, depth int) interface{} {
+	switch s := t.(type) {
+	case *schema.NullField:
+		return nil
+	case *schema.BoolField:
+		return r.Intn(2) == 0
+	case *schema.IntField:
+		return int32(r.Intn(1000) - 500)
+	case *schema.LongField:
+		return r.Int63n(1<<40) - 1<<39
+	case *schema.FloatField:
+		return float32(r.Intn(1000)) / 8
+	case *schema.DoubleField:
+		return float64(r.Intn(1000)) / 8
+	case *schema.BytesField:
+		return []byte(fmt.Sprintf("bytes%v", r.Intn(100)))
+	case *schema.StringField:
+		return fmt.Sprintf("string%v", r.Intn(100))
+	case *schema.ArrayField:
+		items := []interface{}{}
+		if depth < sampleDepth {
+			for i := r.Intn(3); i >= 0; i-- {
+				items = append(items, sampleValue(s.ItemType(), r, depth+1))
+			}
+		}
+		return items
+	case *schema.MapField:
+		items := map[string]interface{}{}
+		if depth < sampleDepth {
+			for i := r.Intn(3); i >= 0; i-- {
+				items[fmt.Sprintf("key%v", i)] = sampleValue(s.ItemType(), r, depth+1)
+			}
+		}
+		return items
+	case *schema.UnionField:
+		branches := s.AvroTypes()
+		if depth >= sampleDepth {
+			for _, b := range branches {
+				if _, ok := b.(*schema.NullField); ok {
+					return nil
+				}
+			}
+		}
+		return sampleValue(branches[r.Intn(len(branches))], r, depth+1)
+	case *schema.Reference:
+		switch def := s.Def.(type) {
+		case *schema.RecordDefinition:
+			fields := map[string]interface{}{}
+			for _, f := range def.Fields() {
+				fields[f.Name()] = sampleValue(f.Type(), r, depth+1)
+			}
+			return fields
+		case *schema.EnumDefinition:
+			symbols := def.Symbols()
+			return symbols[r.Intn(len(symbols))]
+		case *schema.FixedDefinition:
+			return bytes.Repeat([]byte{byte(r.Intn(256))}, def.SizeBytes())
+		}
+	}
+	panic(fmt.Sprintf("No sample value for %v", t.Name()))
+}
+
+// recordingField accepts every operation and appends it to a log shared with the fields it returns
+type recordingField struct {
+	log *[]string
+}
+
+func (f recordingField) add(format string, args ...interface{}) error {
+	*f.log = append(*f.log, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (f recordingField) DeserializeBoolean(v bool) error   { return f.add("boolean %v", v) }
+func (f recordingField) DeserializeInt(v int32) error      { return f.add("int %v", v) }
+func (f recordingField) DeserializeLong(v int64) error     { return f.add("long %v", v) }
+func (f recordingField) DeserializeFloat(v float32) error  { return f.add("float %v", v) }
+func (f recordingField) DeserializeDouble(v float64) error { return f.add("double %v", v) }
+func (f recordingField) DeserializeBytes(v []byte) error   { return f.add("bytes %q", v) }
+func (f recordingField) DeserializeString(v string) error  { return f.add("string %q", v) }
+func (f recordingField) SetDefault(i int) error            { return f.add("default %v", i) }
+func (f recordingField) Finalize() error                   { return f.add("finalize") }
+
+func (f recordingField) Get(i int) (types.Field, error) {
+	return f, f.add("get %v", i)
+}
+
+func (f recordingField) AppendArray() (types.Field, error) {
+	return f, f.add("append")
+}
+
+func (f recordingField) AppendMap(key string) (types.Field, error) {
+	return f, f.add("append %q", key)
+}
+
+// The location in the program is left out of errors, since it differs once the program is optimized
+var errorPC = regexp.MustCompile(` \(pc \d+: [^:]*\)`)
+
+// loggingObserver adds the fields reported to an Observer to the log
+type loggingObserver struct {
+	vm.NopObserver
+	log *[]string
+}
+
+func (o loggingObserver) EnterField(inst vm.Instruction) {
+	*o.log = append(*o.log, "enter field "+inst.Name)
+}
+func (o loggingObserver) ExitField(inst vm.Instruction) {
+	*o.log = append(*o.log, "exit field "+inst.Name)
+}
+func (o loggingObserver) FieldSkipped(field vm.SkippedField) {
+	*o.log = append(*o.log, fmt.Sprintf("skipped field %v %v", field.Index, field.Name))
+}
+
+// decodeLog runs the program over buf with both Eval and EvalBytes, the first with an Observer,
+// and returns the operations on the target followed by the result of each, and then the fields observed.
+// A fused field is finalized by the instruction which reads it, so the observed fields are logged separately.
+func decodeLog(program *vm.Program, buf []byte, opts ...vm.Option) []string {
+	var log, observed []string
+	err := vm.Eval(bytes.NewReader(buf), program, recordingField{&log}, append(opts, vm.Observe(loggingObserver{log: &observed}))...)
+	log = append(log, errorPC.ReplaceAllString(fmt.Sprintf("error %v", err), ""))
+
+	n, err := vm.EvalBytes(buf, program, recordingField{&log}, opts...)
+	log = append(log, errorPC.ReplaceAllString(fmt.Sprintf("error %v", err), ""))
+	log = append(log, fmt.Sprintf("consumed %v", n))
+	return append(log, observed...)
+}
+
+func checkOptimized(t *testing.T, writer, reader schema.AvroType) {
+	program, err := compiler.Compile(writer, reader)
+	if err != nil {
+		t.Skipf("Schemas don't compile - %v", err)
+	}
+	serialized, err := program.MarshalBinary()
+	assert.Nil(t, err)
+
+	assert.Nil(t, program.Verify())
+
+	optimized, err := compiler.Optimize(program)
+	assert.Nil(t, err)
+	assert.Nil(t, optimized.Verify())
+	// The input program isn't modified
+	after, err := program.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, serialized, after)
+
+	encoder, err := generic.NewEncoder(writer)
+	assert.Nil(t, err)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		buf, err := encoder.AppendEncoded(nil, sampleValue(writer, r, 0))
+		assert.Nil(t, err)
+		expected := decodeLog(program, buf)
+		if !assert.Equal(t, expected, decodeLog(optimized, buf), "Decoding %x\n%v\nOptimized:\n%v", buf, program, optimized) {
+			return
+		}
+		// Inlined records count towards MaxDepth, so the limit is hit at the same point
+		for depth := 1; depth <= 4; depth++ {
+			expected := decodeLog(program, buf, vm.MaxDepth(depth))
+			if !assert.Equal(t, expected, decodeLog(optimized, buf, vm.MaxDepth(depth)), "Decoding %x with MaxDepth %v", buf, depth) {
+				return
+			}
+		}
+		// Truncated input fails at the same point
+		for j := 0; j < len(buf); j++ {
+			expected := decodeLog(program, buf[:j])
+			if !assert.Equal(t, expected, decodeLog(optimized, buf[:j]), "Decoding %x", buf[:j]) {
+				return
+			}
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	for dir, schemas := range loadSchemas(t) {
+		for _, writer := range schemas {
+			for _, reader := range schemas {
+				name := writer.name
+				if reader.name != writer.name {
+					name = dir + "/" + strings.Join([]string{filepath.Base(writer.name), filepath.Base(reader.name)}, "->")
+				}
+				t.Run(name, func(t *testing.T) {
+					checkOptimized(t, writer.t, reader.t)
+				})
+			}
+		}
+	}
+}
+
+func TestOptimizeSkipsDroppedFields(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes(
+		[]byte(`{"type": "record", "name": "R", "fields": [
+			{"name": "a", "type": "int"},
+			{"name": "b", "type": "string"},
+			{"name": "c", "type": "double"},
+			{"name": "d", "type": {"type": "fixed", "name": "F", "size": 3}},
+			{"name": "e", "type": "int"}
+		]}`),
+		[]byte(`{"type": "record", "name": "R", "fields": [{"name": "e", "type": "int"}]}`),
+	)
+	assert.Nil(t, err)
+	optimized, err := compiler.Optimize(program)
+	assert.Nil(t, err)
+
+	var ops []vm.Instruction
+	for _, inst := range optimized.Instructions {
+		ops = append(ops, vm.Instruction{Op: inst.Op, Operand: inst.Operand})
+	}
+	// The double and the fixed are skipped together, and the kept field is read without a frame
+	assert.Equal(t, []vm.Instruction{
+		{Op: vm.Call, Operand: 2},
+		{Op: vm.Halt, Operand: 0},
+		{Op: vm.Skip, Operand: vm.Int},
+		{Op: vm.Skip, Operand: vm.String},
+		{Op: vm.Skip, Operand: 11 + 8 + 3},
+		{Op: vm.ReadSetField, Operand: vm.FieldOperand(0, vm.Int)},
+		{Op: vm.Return, Operand: vm.NoopField},
+	}, ops)
+
+	// The merged skip reports both fields, and the second is reported before the next instruction
+	assert.Equal(t, []vm.SkippedField{
+		{Start: 2, End: 6, Index: 0, Name: "a"},
+		{Start: 3, End: 6, Index: 1, Name: "b"},
+		{Start: 4, End: 6, Index: 2, Name: "c"},
+		{Start: 5, End: 6, Index: 3, Name: "d"},
+	}, optimized.SkippedFields)
+}
+
+func TestOptimizeBlocks(t *testing.T) {
+	s := `{"type": "record", "name": "R", "fields": [
+		{"name": "a", "type": {"type": "array", "items": "long"}},
+		{"name": "m", "type": {"type": "map", "values": "string"}}
+	]}`
+	program, err := compiler.CompileSchemaBytes([]byte(s), []byte(s))
+	assert.Nil(t, err)
+	optimized, err := compiler.Optimize(program)
+	assert.Nil(t, err)
+
+	var ops []vm.Op
+	for _, inst := range optimized.Instructions {
+		ops = append(ops, inst.Op)
+	}
+	assert.Equal(t, []vm.Op{
+		vm.Call, vm.Halt,
+		vm.Enter, vm.BlockStart, vm.AppendArray, vm.ReadSet, vm.Exit, vm.BlockEnd, vm.Exit,
+		vm.Enter, vm.BlockStart, vm.Read, vm.AppendMap, vm.ReadSet, vm.Exit, vm.BlockEnd, vm.Exit,
+		vm.Return,
+	}, ops, "%v", optimized)
+}
+
+func BenchmarkOptimize(b *testing.B) {
+	for _, schemas := range loadSchemas(b) {
+		for _, s := range schemas {
+			program, err := compiler.Compile(s.t, s.t)
+			if err != nil {
+				continue
+			}
+			optimized, err := compiler.Optimize(program)
+			if err != nil {
+				b.Fatal(err)
+			}
+			encoder, err := generic.NewEncoder(s.t)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf, err := encoder.AppendEncoded(nil, sampleValue(s.t, rand.New(rand.NewSource(1)), 0))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for _, c := range []struct {
+				name    string
+				program *vm.Program
+			}{{"naive", program}, {"optimized", optimized}} {
+				b.Run(s.name+"/"+c.name, func(b *testing.B) {
+					b.SetBytes(int64(len(buf)))
+					for i := 0; i < b.N; i++ {
+						if _, err := vm.EvalBytes(buf, c.program, types.Discard{}); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"github.com/clear-street/gogen-avro/schema"
+	"github.com/clear-street/gogen-avro/vm"
+)
+
+// Precompiled is a table of programs compiled ahead of time by gogen-avro for a single reader schema.
+// Keys are CRC-64-AVRO fingerprints of the writer schema - both of the exact schema bytes and of the
+// Parsing Canonical Form - and values are programs serialized with vm.Program.MarshalBinary.
+// gogen-avro emits a Precompiled table for each record when it's run with -writer-schemas.
+type Precompiled struct {
+	// The CRC-64-AVRO fingerprint of the exact bytes of the reader schema the programs were compiled for
+	Reader uint64
+	// The programs, keyed by writer schema fingerprint
+	Programs map[uint64]string
+}
+
+// Program returns the program to read data written with `writer` into the structs generated for `reader`.
+// If `reader` is the schema the table was compiled for and an embedded program matches the writer schema,
+// it's loaded directly. Otherwise (or if the embedded program was built for a different version of the VM)
+// the schemas are compiled as with CompileOptimizedSchemaBytes.
+func (p Precompiled) Program(writer, reader []byte) (*vm.Program, error) {
+	if program, ok := p.lookup(writer, reader); ok {
+		return program, nil
+	}
+	return CompileOptimizedSchemaBytes(writer, reader)
+}
+
+func (p Precompiled) lookup(writer, reader []byte) (*vm.Program, bool) {
+	if len(p.Programs) == 0 {
+		return nil, false
+	}
+	if schema.FingerprintBytes(reader) != p.Reader {
+		log("Ignoring precompiled programs compiled for a different reader schema")
+		return nil, false
+	}
+
+	// Try the exact schema bytes first, which doesn't require parsing the schema
+	serialized, ok := p.Programs[schema.FingerprintBytes(writer)]
+	if !ok {
+		fingerprint, err := schema.Fingerprint(writer)
+		if err != nil {
+			return nil, false
+		}
+		if serialized, ok = p.Programs[fingerprint]; !ok {
+			return nil, false
+		}
+	}
+
+	program, err := vm.LoadProgram([]byte(serialized))
+	if err != nil {
+		log("Ignoring precompiled program: %v", err)
+		return nil, false
+	}
+	return program, true
+}
@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/clear-street/gogen-avro/vm"
 )
@@ -17,6 +18,7 @@ type irProgram struct {
 	blocks   []*irBlock
 	switches []*irSwitch
 	errors   []string
+	skipped  []*vm.SkippedField
 }
 
 type irBlock struct {
@@ -48,6 +50,7 @@ func (p *irProgram) createMethod(name string) *irMethod {
 // An IR instruction maps to a fixed number of VM instructions,
 // So we track the length of the finished output to get the real offsets.
 // Main ends with a halt(0), everything else ends with a return.
+// Methods are laid out in name order so the same schemas always compile to the same program.
 func (p *irProgram) CompileToVM() (*vm.Program, error) {
 	irProgram := make([]irInstruction, 0)
 	vmLength := 0
@@ -56,7 +59,14 @@ func (p *irProgram) CompileToVM() (*vm.Program, error) {
 	vmLength += p.main.VMLength()
 	irProgram = append(irProgram, p.main.body...)
 
-	for _, method := range p.methods {
+	methodNames := make([]string, 0, len(p.methods))
+	for name := range p.methods {
+		methodNames = append(methodNames, name)
+	}
+	sort.Strings(methodNames)
+
+	for _, name := range methodNames {
+		method := p.methods[name]
 		method.offset = vmLength
 		method.addLiteral(vm.Return, vm.NoopField, "Return")
 		vmLength += method.VMLength()
@@ -74,9 +84,14 @@ func (p *irProgram) CompileToVM() (*vm.Program, error) {
 		}
 		vmProgram = append(vmProgram, compiled...)
 	}
+	var skipped []vm.SkippedField
+	for _, f := range p.skipped {
+		skipped = append(skipped, *f)
+	}
 	return &vm.Program{
-		Instructions: vmProgram,
-		Errors:       p.errors,
+		Instructions:  vmProgram,
+		Errors:        p.errors,
+		SkippedFields: skipped,
 	}, nil
 }
 
@@ -99,6 +114,12 @@ func (p *irProgram) findOffsets(inst []irInstruction) {
 		case *switchEndIRInstruction:
 			log("findOffsets() block %v - end %v", v.switchId, offset)
 			p.switches[v.switchId].end = offset
+		case *skipFieldIRInstruction:
+			log("findOffsets() skipped field %v - start %v", v.skipId, offset)
+			p.skipped[v.skipId].Start = offset
+		case *skipFieldEndIRInstruction:
+			log("findOffsets() skipped field %v - end %v", v.skipId, offset)
+			p.skipped[v.skipId].End = offset
 		}
 		offset += instruction.VMLength()
 	}
@@ -0,0 +1,94 @@
+package compiler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/generic"
+	"github.com/clear-street/gogen-avro/vm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The Set operand for each primitive type
+var setOperands = map[string]int{
+	"null": vm.Null, "boolean": vm.Boolean, "int": vm.Int, "long": vm.Long,
+	"float": vm.Float, "double": vm.Double, "bytes": vm.Bytes, "string": vm.String,
+}
+
+// The value written for each primitive type, and the value it's read as by each type it can be promoted to
+var promotionValues = map[string]map[string]interface{}{
+	"null":    {"null": nil},
+	"boolean": {"boolean": true},
+	"int":     {"int": int32(-7), "long": int64(-7), "float": float32(-7), "double": float64(-7)},
+	"long":    {"long": int64(1) << 40, "float": float32(1 << 40), "double": float64(1 << 40)},
+	"float":   {"float": float32(1.5), "double": float64(1.5)},
+	"double":  {"double": float64(-2.25)},
+	"bytes":   {"bytes": []byte("ab"), "string": "ab"},
+	"string":  {"string": "cd", "bytes": []byte("cd")},
+}
+
+func TestPromotions(t *testing.T) {
+	for writer, readable := range promotionValues {
+		for reader := range promotionValues {
+			t.Run(writer+"->"+reader, func(t *testing.T) {
+				writerType, err := generic.ParseSchema([]byte(fmt.Sprintf("%q", writer)))
+				assert.Nil(t, err)
+				readerType, err := generic.ParseSchema([]byte(fmt.Sprintf("%q", reader)))
+				assert.Nil(t, err)
+
+				expected, ok := readable[reader]
+				program, err := compiler.Compile(writerType, readerType)
+				if !ok {
+					assert.NotNil(t, err, "%v shouldn't be readable as %v", writer, reader)
+					return
+				}
+				assert.Nil(t, err)
+				// The value is always set as the reader's type
+				for _, inst := range program.Instructions {
+					if inst.Op == vm.Set {
+						assert.Equal(t, setOperands[reader], inst.Operand)
+					}
+				}
+
+				encoder, err := generic.NewEncoder(writerType)
+				assert.Nil(t, err)
+				buf, err := encoder.AppendEncoded(nil, readable[writer])
+				assert.Nil(t, err)
+				decoder, err := generic.NewDecoder(writerType, readerType)
+				assert.Nil(t, err)
+				v, n, err := decoder.DecodeBytes(buf)
+				assert.Nil(t, err)
+				assert.Equal(t, len(buf), n)
+				assert.Equal(t, expected, v)
+			})
+		}
+	}
+}
+
+func TestPromotionsInUnion(t *testing.T) {
+	// The int is read into the first branch it can be promoted to, and the string into bytes
+	writerType, err := generic.ParseSchema([]byte(`["int", "string"]`))
+	assert.Nil(t, err)
+	readerType, err := generic.ParseSchema([]byte(`["null", "double", "long", "bytes"]`))
+	assert.Nil(t, err)
+	decoder, err := generic.NewDecoder(writerType, readerType)
+	assert.Nil(t, err)
+	encoder, err := generic.NewEncoder(writerType)
+	assert.Nil(t, err)
+
+	for _, c := range []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{int32(3), float64(3)},
+		{"x", []byte("x")},
+	} {
+		buf, err := encoder.AppendEncoded(nil, c.value)
+		assert.Nil(t, err)
+		v, _, err := decoder.DecodeBytes(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, v)
+	}
+}
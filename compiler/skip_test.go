@@ -0,0 +1,117 @@
+package compiler_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const skipWriterSchema = `{"type": "record", "name": "R", "fields": [
+	{"name": "items", "type": {"type": "array", "items": "string"}},
+	{"name": "scores", "type": {"type": "map", "values": "long"}},
+	{"name": "payload", "type": "bytes"},
+	{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 4}},
+	{"name": "kept", "type": "int"}
+]}`
+
+const skipReaderSchema = `{"type": "record", "name": "R", "fields": [{"name": "kept", "type": "int"}]}`
+
+func appendVarint(b []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(b, scratch[:binary.PutVarint(scratch[:], v)]...)
+}
+
+// appendBlock encodes an array or map block with its byte size, which is signalled by a negative count
+func appendBlock(b []byte, count int64, items []byte) []byte {
+	b = appendVarint(b, -count)
+	b = appendVarint(b, int64(len(items)))
+	return append(b, items...)
+}
+
+// encodeSkipped encodes a record for skipWriterSchema with the payload, mixing blocks with and without byte sizes
+func encodeSkipped(payload []byte) []byte {
+	var items []byte
+	items = appendVarint(items, 1)
+	items = append(items, 'a')
+	items = appendVarint(items, 2)
+	items = append(items, 'b', 'c')
+
+	b := appendBlock(nil, 2, items)
+	b = appendVarint(b, 1)
+	b = append(appendVarint(b, 1), 'd')
+	b = appendVarint(b, 0)
+
+	var entries []byte
+	entries = append(appendVarint(entries, 1), 'k')
+	entries = appendVarint(entries, 1<<40)
+	b = appendBlock(b, 1, entries)
+	b = appendBlock(b, 1, entries)
+	b = appendVarint(b, 0)
+
+	b = append(appendVarint(b, int64(len(payload))), payload...)
+	b = append(b, 1, 2, 3, 4)
+	return appendVarint(b, 42)
+}
+
+func TestCompileSkipsDroppedFields(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(skipWriterSchema), []byte(skipReaderSchema))
+	assert.Nil(t, err)
+
+	// Nothing but the kept field is read into the frame
+	reads := 0
+	for _, inst := range program.Instructions {
+		if inst.Op == vm.Read && inst.Operand != vm.UnusedLong {
+			reads++
+		}
+	}
+	assert.Equal(t, 1, reads, "%v", program)
+
+	buf := encodeSkipped([]byte("payload"))
+	var log []string
+	n, err := vm.EvalBytes(buf, program, recordingField{&log})
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, []string{"get 0", "int 42", "finalize"}, log)
+
+	log = nil
+	assert.Nil(t, vm.Eval(bytes.NewReader(buf), program, recordingField{&log}))
+	assert.Equal(t, []string{"get 0", "int 42", "finalize"}, log)
+}
+
+func TestCompileSkipBlockTruncated(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(skipWriterSchema), []byte(skipReaderSchema))
+	assert.Nil(t, err)
+
+	// The block claims more bytes than there are
+	buf := appendVarint(appendVarint(nil, -1), 100)
+	_, err = vm.EvalBytes(buf, program, types.Discard{})
+	assert.NotNil(t, err)
+	assert.NotNil(t, vm.Eval(bytes.NewReader(buf), program, types.Discard{}))
+
+	buf = appendVarint(appendVarint(nil, -1), -1)
+	_, err = vm.EvalBytes(buf, program, types.Discard{})
+	assert.Contains(t, err.Error(), "block size out of range")
+}
+
+func TestCompileSkipDoesNotAllocate(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(skipWriterSchema), []byte(skipReaderSchema))
+	assert.Nil(t, err)
+	buf := encodeSkipped(make([]byte, 1<<20))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, vm.Eval(bytes.NewReader(buf), program, types.Discard{}))
+		_, err := vm.EvalBytes(buf, program, types.Discard{})
+		assert.Nil(t, err)
+	}
+	runtime.ReadMemStats(&after)
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 1<<20, "Allocated %v bytes", after.TotalAlloc-before.TotalAlloc)
+}
@@ -0,0 +1,135 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// truncater is implemented by files which can be cut short, like *os.File
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// NewAppendWriter opens an existing OCF file to write more blocks to the end of it. The header is read to get
+// the codec and sync marker, which are used for the new blocks, and the file's schema must have the same canonical
+// form as schema. You can create an append Writer for a given struct by calling the generated method
+// `New<RecordType>AppendWriter`.
+//
+// The blocks already in the file are checked by seeking past each one and reading its sync marker, without reading
+// the records. If the file ends with an incomplete block, like one left behind by a crash, the file is truncated to
+// remove it, which requires file to have a Truncate method like *os.File does. Otherwise NewAppendWriter
+// returns an error.
+//
+// The header can't be changed, so the Metadata, RandomSync and SyncMarker options aren't allowed.
+func NewAppendWriter(file io.ReadWriteSeeker, recordsPerBlock int64, schema string, opts ...WriterOption) (*Writer, error) {
+	config, err := newWriterConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.metadata) > 0 || config.syncSet {
+		return nil, fmt.Errorf("Can't change the header of a file being appended to")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSameSchema([]byte(schema), reader.AvroContainerSchema()); err != nil {
+		return nil, err
+	}
+
+	end, err := endOfBlocks(file, reader.SyncMarker())
+	if err != nil {
+		return nil, err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end < size {
+		t, ok := file.(truncater)
+		if !ok {
+			return nil, fmt.Errorf("File has an incomplete block of %v bytes at offset %v, and can't be truncated", size-end, end)
+		}
+		log("OCF truncating incomplete block at offset %v", end)
+		if err := t.Truncate(end); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	config.syncMarker = reader.SyncMarker()
+	avroWriter, err := newWriter(file, reader.Codec(), recordsPerBlock, schema, config)
+	if err != nil {
+		return nil, err
+	}
+	avroWriter.writer.n = end
+	return avroWriter, nil
+}
+
+// endOfBlocks seeks over the blocks in a file, starting from the current position right after the header,
+// and returns the offset of the end of the last complete block with the right sync marker
+func endOfBlocks(file io.ReadSeeker, sync [16]byte) (int64, error) {
+	end, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	for end < size {
+		if _, err := readVarint(file); err != nil {
+			return end, nil
+		}
+		length, err := readVarint(file)
+		if err != nil || length < 0 {
+			return end, nil
+		}
+		offset, err := file.Seek(length, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		if offset+16 > size {
+			return end, nil
+		}
+		var marker [16]byte
+		if _, err := io.ReadFull(file, marker[:]); err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(marker[:], sync[:]) {
+			return end, nil
+		}
+		end = offset + 16
+	}
+	return end, nil
+}
+
+// readVarint reads a zig-zag encoded long one byte at a time, so it doesn't read past the end of the value
+func readVarint(r io.Reader) (int64, error) {
+	var v uint64
+	var b [1]byte
+	for shift := uint(0); ; shift += 7 {
+		if shift >= 64 {
+			return 0, fmt.Errorf("Varint overflows a 64-bit integer")
+		}
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		v |= uint64(b[0]&127) << shift
+		if b[0]&128 == 0 {
+			break
+		}
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
@@ -0,0 +1,102 @@
+package container
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+)
+
+// A Compressor returns the compressed form of the encoded records in a block
+type Compressor func(block []byte) ([]byte, error)
+
+// A Decompressor returns the encoded records in a block compressed by the matching Compressor
+type Decompressor func(block []byte) ([]byte, error)
+
+type codecFuncs struct {
+	compress   Compressor
+	decompress Decompressor
+}
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = make(map[Codec]codecFuncs)
+)
+
+func init() {
+	RegisterCodec(Null, nullCodec, nullCodec)
+	RegisterCodec(Deflate, deflateCompressor(flate.DefaultCompression), deflateDecompress)
+	RegisterCodec(Snappy, snappyCompress, snappyDecompress)
+}
+
+// RegisterCodec makes a codec available to Writers and Readers under the name written to the avro.codec
+// header entry. Registering a name again replaces the codec, including the built-in null, deflate and snappy.
+// The functions may be called from several goroutines at once.
+func RegisterCodec(name Codec, compressor Compressor, decompressor Decompressor) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecs[name] = codecFuncs{compress: compressor, decompress: decompressor}
+}
+
+func lookupCodec(name Codec) (codecFuncs, error) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return c, fmt.Errorf("Unexpected codec %q", name)
+	}
+	return c, nil
+}
+
+func nullCodec(block []byte) ([]byte, error) {
+	return block, nil
+}
+
+func deflateCompressor(level int) Compressor {
+	return func(block []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(block); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func deflateDecompress(block []byte) ([]byte, error) {
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(block)))
+}
+
+// Snappy blocks are followed by the big-endian CRC32 of the uncompressed data, as required by the Avro spec
+func snappyCompress(block []byte) ([]byte, error) {
+	compressed := snappy.Encode(nil, block)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(block))
+	return append(compressed, crc[:]...), nil
+}
+
+func snappyDecompress(block []byte) ([]byte, error) {
+	if len(block) < 4 {
+		return nil, fmt.Errorf("Snappy block too short - %v bytes", len(block))
+	}
+	data, err := snappy.Decode(nil, block[:len(block)-4])
+	if err != nil {
+		return nil, err
+	}
+	expected := binary.BigEndian.Uint32(block[len(block)-4:])
+	if crc := crc32.ChecksumIEEE(data); crc != expected {
+		return nil, fmt.Errorf("Snappy block checksum %08x doesn't match the data, expected %08x", crc, expected)
+	}
+	return data, nil
+}
@@ -0,0 +1,204 @@
+package container
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/clear-street/gogen-avro/container/avro"
+)
+
+// BlockDecoder decodes every record in a block, given the uncompressed records and the number of records the
+// block holds, and returns them in a form the caller of ParallelReader.Next understands - usually a slice of
+// generated structs. Each worker has its own BlockDecoder, which it calls for every block it decodes.
+type BlockDecoder func(records []byte, count int64) (interface{}, error)
+
+// NewBlockDecoder returns the BlockDecoder for a worker, which can keep state like a vm.Evaluator
+// between the blocks it decodes. It's called from several goroutines at once.
+type NewBlockDecoder func() BlockDecoder
+
+// ErrReaderClosed is returned by ParallelReader.Next after the reader has been closed.
+var ErrReaderClosed = errors.New("Reader closed")
+
+// ParallelReader reads the blocks of an OCF file ahead of the caller, and decompresses and decodes them on
+// a pool of worker goroutines. Decoded blocks are still returned in the order they appear in the file.
+// You can create a ParallelReader for a given struct by calling the generated method `New<RecordType>ParallelReader`.
+//
+// A ParallelReader must be closed once it's no longer needed, to stop the goroutines reading ahead.
+// Next must not be called from multiple goroutines at the same time.
+type ParallelReader struct {
+	reader *Reader
+	// The blocks read from the file, in order. The workers send each block's result on its own channel.
+	pending chan chan blockResult
+	jobs    chan blockJob
+	done    chan struct{}
+	close   sync.Once
+	// The first error returned by Next, which is returned again by every later call
+	err error
+	// The report function of a Reader with the SkipCorruption option, called from Next
+	report func(Corruption)
+}
+
+type blockJob struct {
+	block  []byte
+	count  int64
+	result chan blockResult
+	// The range of the file the block was read from
+	start, end int64
+}
+
+type blockResult struct {
+	records interface{}
+	err     error
+	// Set instead of err for a block skipped by a Reader with the SkipCorruption option
+	corruption *Corruption
+}
+
+// NewParallelReader starts decoding the blocks of the file read by r with the given number of workers,
+// which defaults to GOMAXPROCS if it's zero or less. At most two blocks per worker are held in memory waiting
+// to be returned by Next. The ParallelReader takes over r, which must not be read from directly afterwards.
+func NewParallelReader(r *Reader, workers int, newDecoder NewBlockDecoder) *ParallelReader {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &ParallelReader{
+		reader:  r,
+		pending: make(chan chan blockResult, 2*workers),
+		jobs:    make(chan blockJob, workers),
+		done:    make(chan struct{}),
+	}
+	// Damaged framing is found while reading ahead, so it's queued up with the blocks to be reported
+	// from Next in the order it appears in the file
+	if r.report != nil {
+		p.report = r.report
+		r.report = func(c Corruption) {
+			result := make(chan blockResult, 1)
+			result <- blockResult{corruption: &c}
+			p.enqueue(result)
+		}
+	}
+
+	go p.readBlocks()
+	for i := 0; i < workers; i++ {
+		go p.decodeBlocks(newDecoder)
+	}
+	return p
+}
+
+// AvroContainerSchema returns the schema the file was written with
+func (p *ParallelReader) AvroContainerSchema() []byte {
+	return p.reader.AvroContainerSchema()
+}
+
+// Metadata returns a copy of the metadata in the file header, like Reader.Metadata
+func (p *ParallelReader) Metadata() map[string][]byte {
+	return p.reader.Metadata()
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (p *ParallelReader) Codec() Codec {
+	return p.reader.Codec()
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (p *ParallelReader) SyncMarker() avro.Sync {
+	return p.reader.SyncMarker()
+}
+
+// readBlocks hands each block in the file to the workers, and queues up the channel its result
+// will be sent on so Next returns the results in order. The error which ends the file, io.EOF
+// if it ends cleanly, is queued up last.
+func (p *ParallelReader) readBlocks() {
+	defer close(p.jobs)
+	for {
+		result := make(chan blockResult, 1)
+		block, err := p.reader.readBlock()
+		if err != nil {
+			result <- blockResult{err: err}
+			p.enqueue(result)
+			return
+		}
+
+		select {
+		case p.jobs <- blockJob{block: block.RecordBytes, count: block.NumRecords, result: result, start: p.reader.blockStart, end: p.reader.reader.n}:
+		case <-p.done:
+			return
+		}
+		if !p.enqueue(result) {
+			return
+		}
+	}
+}
+
+// enqueue adds the channel for the next result to the ones waiting for Next, and returns false if the reader
+// was closed first
+func (p *ParallelReader) enqueue(result chan blockResult) bool {
+	select {
+	case p.pending <- result:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// decodeBlocks runs on each worker until the blocks run out. The result channels are buffered,
+// so a worker never waits for Next.
+func (p *ParallelReader) decodeBlocks(newDecoder NewBlockDecoder) {
+	decode := newDecoder()
+	for job := range p.jobs {
+		var result blockResult
+		var records []byte
+		if records, result.err = p.reader.decompress(job.block); result.err == nil {
+			result.records, result.err = decode(records, job.count)
+		}
+		if result.err != nil && p.report != nil {
+			result.corruption = &Corruption{Start: job.start, End: job.end, Records: job.count, Err: result.err}
+			result.err = nil
+		}
+		job.result <- result
+	}
+}
+
+// Next returns the records decoded from the next block in the file, as returned by the BlockDecoder.
+// It returns io.EOF once every block has been read. After any error, including io.EOF, every later
+// call returns the same error.
+func (p *ParallelReader) Next() (interface{}, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	select {
+	case <-p.done:
+		p.err = ErrReaderClosed
+		return nil, p.err
+	default:
+	}
+
+	for {
+		var result blockResult
+		select {
+		case pending := <-p.pending:
+			result = <-pending
+		case <-p.done:
+			result.err = ErrReaderClosed
+		}
+		if result.err != nil {
+			p.err = result.err
+			return nil, p.err
+		}
+		if result.corruption != nil {
+			p.report(*result.corruption)
+			continue
+		}
+		return result.records, nil
+	}
+}
+
+// Close stops reading ahead. The goroutines reading and decoding blocks exit once they've finished the
+// block they're working on. Close doesn't close the underlying io.Reader, and it may be called while
+// another goroutine is waiting in Next, which then returns ErrReaderClosed.
+func (p *ParallelReader) Close() error {
+	p.close.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
@@ -0,0 +1,91 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Position is the position of a record in an OCF file - the offset of the block it's in, and its index in the block.
+// Save it with MarshalText to checkpoint progress through a file, and resume reading from it with NewReaderAt or
+// the generated `New<RecordType>ReaderAt`.
+type Position struct {
+	block  int64
+	record int64
+}
+
+// BlockOffset returns the offset in the file of the start of the block the record is in
+func (p Position) BlockOffset() int64 {
+	return p.block
+}
+
+// Record returns the index of the record in its block
+func (p Position) Record() int64 {
+	return p.record
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%v:%v", p.block, p.record)
+}
+
+func (p Position) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Position) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid position %q", text)
+	}
+	block, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || block < 0 {
+		return fmt.Errorf("Invalid position %q", text)
+	}
+	record, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || record < 0 {
+		return fmt.Errorf("Invalid position %q", text)
+	}
+	p.block = block
+	p.record = record
+	return nil
+}
+
+// Position returns the position of the next record to be decoded from the Reader. After the last record of a block
+// it's the start of the next block.
+func (r *Reader) Position() Position {
+	if r.compressedReader == nil || r.recordIndex >= r.blockRecords {
+		return Position{block: r.reader.n}
+	}
+	return Position{block: r.blockStart, record: r.recordIndex}
+}
+
+// RecordRead tells the Reader that a record was decoded from it, so Position moves on to the next record.
+// The generated readers call it after each record.
+func (r *Reader) RecordRead() {
+	r.recordIndex++
+}
+
+// NewReaderAt reads the header of the OCF file read by r, then seeks to the block of pos. The records before pos
+// in the block still have to be skipped by the caller, which the generated `New<RecordType>ReaderAt` does by
+// decoding them into types.Discard. Reading from a position in the middle of a block fails if the block has fewer records than expected.
+func NewReaderAt(r io.ReadSeeker, pos Position, opts ...ReaderOption) (*Reader, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader, err := NewReader(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if pos.block < reader.reader.n {
+		return nil, fmt.Errorf("Position %v is inside the file header, which ends at offset %v", pos, reader.reader.n)
+	}
+
+	log("OCF reader resuming at position %v", pos)
+	if _, err := r.Seek(pos.block, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader.reader = &countingReader{r: r, n: pos.block}
+	reader.resume = pos.record
+	return reader, nil
+}
@@ -2,12 +2,9 @@ package container
 
 import (
 	"bytes"
-	"compress/flate"
 	"fmt"
 	"io"
 
-	"github.com/golang/snappy"
-
 	"github.com/clear-street/gogen-avro/container/avro"
 	"github.com/clear-street/gogen-avro/schema"
 )
@@ -16,20 +13,56 @@ import (
 // Generally you can create a Reader using the `New<RecordType>Reader` method generate for every record type.
 type Reader struct {
 	codec            Codec
-	reader           io.Reader
+	reader           *countingReader
 	compressedReader io.Reader
 	schemaBytes      []byte
 	schema           schema.AvroType
 	sync             avro.Sync
+	metadata         map[string][]byte
+	decompress       Decompressor
+	// The offset of the sync marker after which no more blocks are read, or -1 to read to the end of the file
+	end int64
+	// The offset of the start of the last block read
+	blockStart int64
+	// Set by the SkipCorruption option
+	report func(Corruption)
+	// The records and bytes in the blocks read so far, to estimate the records lost to corruption
+	recordsRead int64
+	bytesRead   int64
+	// The number of records in the open block, and the number decoded from it so far
+	blockRecords int64
+	recordIndex  int64
+	// The record index of the Position a Reader created by NewReaderAt starts at, checked when the block is opened
+	resume int64
+}
+
+// A ReaderOption changes how a Reader reads the file
+type ReaderOption func(*Reader)
+
+// SkipCorruption makes the Reader carry on past a damaged part of the file instead of returning an error.
+// When a block can't be read, the Reader scans forward to the next sync marker and calls report with the range
+// of bytes it skipped, then reads the block after it. Blocks which fail to decompress are skipped in the same way.
+// The records lost are estimated from the average size of the blocks read so far, or taken from the block itself
+// when only its contents are damaged.
+//
+// Only the framing and compression of the blocks are checked, so a record which fails to decode is still
+// returned as an error by the generated Reader. A ParallelReader decodes whole blocks, so it also skips blocks
+// with records which fail to decode. It always calls report from Next, in the order the damage appears in the file.
+func SkipCorruption(report func(Corruption)) ReaderOption {
+	return func(r *Reader) {
+		r.report = report
+	}
 }
 
-func NewReader(r io.Reader) (*Reader, error) {
-	header, err := avro.DeserializeAvroContainerHeader(r)
+// NewReader reads the header of the OCF file read by r, leaving r positioned at the first block.
+func NewReader(r io.Reader, opts ...ReaderOption) (*Reader, error) {
+	counter := &countingReader{r: r}
+	header, err := avro.DeserializeAvroContainerHeader(counter)
 	if err != nil {
 		return nil, err
 	}
 
-	if bytes.Equal(header.Magic[:], []byte{'o', 'b', 'j', 1}) {
+	if !bytes.Equal(header.Magic[:], []byte{'O', 'b', 'j', 1}) {
 		return nil, fmt.Errorf("Unexpected magic in header - %v", header.Magic)
 	}
 
@@ -39,26 +72,57 @@ func NewReader(r io.Reader) (*Reader, error) {
 	}
 	log("Got OCF schema from header: %v", string(schemaBytes))
 
-	codec, ok := header.Meta["avro.codec"]
-	if !ok {
-		return nil, fmt.Errorf("Expected avro.codec in header, not specified in metadata map - %v", header.Meta)
+	// The codec is optional, and defaults to null
+	codec := []byte(Null)
+	if c, ok := header.Meta["avro.codec"]; ok {
+		codec = c
 	}
 	log("Got OCF codec from header: %v", string(codec))
+	codecFuncs, err := lookupCodec(Codec(codec))
+	if err != nil {
+		return nil, err
+	}
 
-	return &Reader{
+	reader := &Reader{
 		codec:            Codec(codec),
-		reader:           r,
+		reader:           counter,
 		schemaBytes:      schemaBytes,
 		compressedReader: nil,
 		schema:           nil,
 		sync:             header.Sync,
-	}, nil
+		metadata:         header.Meta,
+		decompress:       codecFuncs.decompress,
+		end:              -1,
+	}
+	for _, o := range opts {
+		o(reader)
+	}
+	return reader, nil
 }
 
 func (r *Reader) AvroContainerSchema() []byte {
 	return r.schemaBytes
 }
 
+// Metadata returns a copy of the metadata in the file header, including the avro.schema and avro.codec entries
+func (r *Reader) Metadata() map[string][]byte {
+	metadata := make(map[string][]byte, len(r.metadata))
+	for key, value := range r.metadata {
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (r *Reader) Codec() Codec {
+	return r.codec
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (r *Reader) SyncMarker() avro.Sync {
+	return r.sync
+}
+
 func (r *Reader) Read(b []byte) (n int, err error) {
 	if r.compressedReader == nil {
 		log("OCF reader opening new block")
@@ -86,37 +150,94 @@ func (r *Reader) Read(b []byte) (n int, err error) {
 }
 
 func (r *Reader) openBlock() error {
-	header, err := avro.DeserializeAvroContainerBlock(r.reader)
+	for {
+		block, err := r.readBlock()
+		if err != nil {
+			return err
+		}
+
+		records, err := r.decompress(block.RecordBytes)
+		if err != nil && r.report != nil {
+			r.report(Corruption{Start: r.blockStart, End: r.reader.n, Records: block.NumRecords, Err: err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if r.resume > 0 && r.resume >= block.NumRecords {
+			return fmt.Errorf("Position record %v is past the end of the block at offset %v, which has %v records", r.resume, r.blockStart, block.NumRecords)
+		}
+		r.resume = 0
+		r.compressedReader = bytes.NewBuffer(records)
+		r.blockRecords = block.NumRecords
+		r.recordIndex = 0
+		return nil
+	}
+}
+
+// ReadBlock returns the next block in the file without decompressing or decoding it, or io.EOF after the last block.
+// Blocks can be copied to another file with Writer.WriteBlock. ReadBlock can't be mixed with Read, which reads
+// the records inside the blocks.
+func (r *Reader) ReadBlock() (*avro.AvroContainerBlock, error) {
+	return r.readBlock()
+}
+
+// readBlock reads the next block from the file, still compressed, and checks its sync marker
+func (r *Reader) readBlock() (*avro.AvroContainerBlock, error) {
+	// Each block belongs to the split its preceding sync marker starts in
+	if r.end >= 0 && r.reader.n-syncSize >= r.end {
+		return nil, io.EOF
+	}
+	if r.report != nil {
+		return r.readBlockLeniently()
+	}
+
+	// readFraming checks the block size and sync marker, and doesn't allocate more than the rest of the file
+	// for a damaged size
+	r.blockStart = r.reader.n
+	block, _, err := r.readFraming()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	log("OCF block size: %v", len(block.RecordBytes))
+	return block, nil
+}
+
+// countingReader counts the bytes read through it, to track the offset in the file
+type countingReader struct {
+	r io.Reader
+	n int64
+	// Bytes which were read and then pushed back, returned before any more are read from r
+	unread []byte
+}
 
-	log("OCF block size: %v", len(header.RecordBytes))
-	if header.Sync != r.sync {
-		return fmt.Errorf("Unexpected sync marker %q, expected %q", header.Sync, r.sync)
+func (c *countingReader) Read(b []byte) (int, error) {
+	if len(c.unread) > 0 {
+		n := copy(b, c.unread)
+		c.unread = c.unread[n:]
+		c.n += int64(n)
+		return n, nil
 	}
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
 
-	blockBuffer := bytes.NewBuffer(header.RecordBytes)
+// unreadBytes pushes b back, to be read again before anything else
+func (c *countingReader) unreadBytes(b []byte) {
+	c.unread = append(append([]byte{}, b...), c.unread...)
+	c.n -= int64(len(b))
+}
 
-	switch r.codec {
-	case Null:
-		r.compressedReader = blockBuffer
-		break
-	case Deflate:
-		r.compressedReader = flate.NewReader(blockBuffer)
-		break
-	case Snappy:
-		// TODO: Check the last 4 bytes are the big-endian CRC of the compressed Snappy block
-		dst := make([]byte, 0, 0)
-		dst, err := snappy.Decode(nil, header.RecordBytes[:len(header.RecordBytes)-4])
-		if err != nil {
-			return err
+func (c *countingReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if br, ok := c.r.(io.ByteReader); ok && len(c.unread) == 0 {
+		v, err := br.ReadByte()
+		if err == nil {
+			c.n++
 		}
-		r.compressedReader = bytes.NewBuffer(dst)
-		break
-	default:
-		return fmt.Errorf("Unexpected codec %q", r.codec)
+		return v, err
 	}
-
-	return nil
+	_, err := io.ReadFull(c, b[:])
+	return b[0], err
 }
@@ -0,0 +1,153 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/clear-street/gogen-avro/container/avro"
+)
+
+// Corruption describes a damaged part of a file skipped by a Reader with the SkipCorruption option
+type Corruption struct {
+	// The byte range skipped, from the start of the damaged block to the end of the next sync marker
+	// or the end of the file. A split reader scans past the end of its split to the first sync marker after it,
+	// which is where the next split starts reading, so damage in between is reported by exactly one split.
+	Start, End int64
+	// The estimated number of records lost
+	Records int64
+	// The error which the damaged block caused
+	Err error
+}
+
+func (c Corruption) Error() string {
+	return fmt.Sprintf("Skipped bytes %v-%v, about %v records - %v", c.Start, c.End, c.Records, c.Err)
+}
+
+// readBlockLeniently reads the next block like readBlock, but when the block's framing is damaged it scans
+// forward to the next sync marker, reports the bytes skipped and tries again from there
+func (r *Reader) readBlockLeniently() (*avro.AvroContainerBlock, error) {
+	for {
+		if r.end >= 0 && r.reader.n-syncSize >= r.end {
+			return nil, io.EOF
+		}
+
+		r.blockStart = r.reader.n
+		block, raw, err := r.readFraming()
+		if err == nil {
+			r.recordsRead += block.NumRecords
+			r.bytesRead += int64(len(raw))
+			return block, nil
+		}
+		if len(raw) == 0 {
+			// The file ends cleanly after the last block, or can't be read at all
+			return nil, err
+		}
+		log("OCF skipping damaged block at offset %v - %v", r.blockStart, err)
+
+		found, scanErr := r.skipToSync(raw)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		r.report(Corruption{
+			Start:   r.blockStart,
+			End:     r.reader.n,
+			Records: r.estimateRecords(block, r.reader.n-r.blockStart),
+			Err:     err,
+		})
+		if !found {
+			return nil, io.EOF
+		}
+	}
+}
+
+// readFraming reads a block and checks its sync marker, returning every byte it consumed so the reader can
+// scan them for a sync marker if the block is damaged. The block's record count is returned whenever it was read.
+func (r *Reader) readFraming() (*avro.AvroContainerBlock, []byte, error) {
+	var raw bytes.Buffer
+	tee := io.TeeReader(r.reader, &raw)
+	block := &avro.AvroContainerBlock{NumRecords: -1}
+
+	count, err := readVarint(tee)
+	if err != nil {
+		return nil, raw.Bytes(), err
+	}
+	if count < 0 {
+		return nil, raw.Bytes(), fmt.Errorf("Invalid block record count %v", count)
+	}
+	block.NumRecords = count
+
+	length, err := readVarint(tee)
+	if err != nil {
+		return block, raw.Bytes(), err
+	}
+	// A size near MaxInt64 would overflow once the sync marker is added to it
+	if length < 0 || length > math.MaxInt64-syncSize {
+		return block, raw.Bytes(), fmt.Errorf("Invalid block size %v", length)
+	}
+	// The buffer grows as the data is read, so a damaged size doesn't allocate more than the rest of the file
+	dataStart := raw.Len()
+	if _, err := io.CopyN(&raw, r.reader, length+syncSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return block, raw.Bytes(), err
+	}
+
+	b := raw.Bytes()
+	if int64(len(b)-dataStart) != length+syncSize {
+		return block, b, io.ErrUnexpectedEOF
+	}
+	block.RecordBytes = b[dataStart : len(b)-syncSize]
+	copy(block.Sync[:], b[len(b)-syncSize:])
+	if block.Sync != r.sync {
+		return block, b, fmt.Errorf("Unexpected sync marker %q, expected %q", block.Sync, r.sync)
+	}
+	return block, b, nil
+}
+
+// skipToSync searches the bytes already consumed, then the rest of the file, for the next sync marker.
+// If one is found the reader is left right after it, otherwise it's left at the end of the file.
+// A split reader doesn't stop at the end of its split, since the next split only starts reading after
+// the first marker at or after it. If the marker found starts past the end, readBlock then stops there.
+func (r *Reader) skipToSync(consumed []byte) (bool, error) {
+	// A marker at the very start would still skip its own 16 bytes, so the reader always makes progress
+	buf := append([]byte{}, consumed...)
+	chunk := make([]byte, scanChunkSize)
+	for {
+		if i := bytes.Index(buf, r.sync[:]); i >= 0 {
+			r.reader.unreadBytes(buf[i+syncSize:])
+			return true, nil
+		}
+		// Keep enough bytes to find a marker which spans two chunks
+		if len(buf) >= syncSize {
+			buf = append(buf[:0], buf[len(buf)-syncSize+1:]...)
+		}
+
+		n, err := r.reader.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err == io.EOF {
+			if i := bytes.Index(buf, r.sync[:]); i >= 0 {
+				r.reader.unreadBytes(buf[i+syncSize:])
+				return true, nil
+			}
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// estimateRecords estimates the number of records in a damaged range of the file, from the average size
+// of the blocks read so far or, before any have been read, the record count of the damaged block
+func (r *Reader) estimateRecords(block *avro.AvroContainerBlock, skipped int64) int64 {
+	if r.bytesRead > 0 {
+		return skipped * r.recordsRead / r.bytesRead
+	}
+	if block != nil && block.NumRecords >= 0 {
+		return block.NumRecords
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The size of the sync marker written after the header and every block
+const syncSize = 16
+
+// The number of bytes read at a time while scanning for a sync marker
+const scanChunkSize = 64 * 1024
+
+// NewSplitReader returns a Reader for the blocks of an OCF file which belong to the byte range [start, end),
+// so many workers can each read part of one large file without overlap, like Hadoop input splits.
+// The header is read from the start of the file. Reading then starts after the first sync marker at or after start,
+// and stops after the block which crosses end - that is, a block belongs to the range its preceding sync marker
+// starts in. Splitting a file into ranges which cover it, in any way, reads every block exactly once.
+//
+// ReadAt doesn't change the offset of an *os.File, so workers can share one open file.
+func NewSplitReader(r io.ReaderAt, start, end int64, opts ...ReaderOption) (*Reader, error) {
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("Invalid split %v-%v", start, end)
+	}
+
+	header := io.NewSectionReader(r, 0, math.MaxInt64)
+	reader, err := NewReader(bufio.NewReader(header), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header ends with the first sync marker, so there's no need to scan for it
+	offset := reader.reader.n
+	if start > offset-syncSize {
+		marker, err := findSync(r, start, end, reader.sync)
+		if err != nil {
+			return nil, err
+		}
+		offset = marker + syncSize
+	}
+	log("OCF split %v-%v starts reading at offset %v", start, end, offset)
+
+	reader.reader = &countingReader{
+		r: bufio.NewReader(io.NewSectionReader(r, offset, math.MaxInt64-offset)),
+		n: offset,
+	}
+	reader.end = end
+	return reader, nil
+}
+
+// findSync returns the offset of the first sync marker which starts in [start, end), or end if there isn't one
+func findSync(r io.ReaderAt, start, end int64, sync [syncSize]byte) (int64, error) {
+	buf := make([]byte, scanChunkSize+syncSize-1)
+	for offset := start; offset < end; offset += scanChunkSize {
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.Index(buf[:n], sync[:]); i >= 0 && offset+int64(i) < end {
+			return offset + int64(i), nil
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return end, nil
+}
@@ -4,9 +4,15 @@ package container
 import (
 	"bytes"
 	"compress/flate"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
 
 	"github.com/clear-street/gogen-avro/container/avro"
+	"github.com/clear-street/gogen-avro/schema"
 )
 
 // A Codec specifies how the blocks within a container file should be compressed.
@@ -23,21 +29,125 @@ const (
 	Snappy Codec = "snappy"
 )
 
-type CloseableResettableWriter interface {
-	Close() error
-	Reset(io.Writer)
+// The sync marker written to files unless the RandomSync or SyncMarker option is given
+var defaultSyncMarker = avro.Sync{'g', 'o', 'g', 'e', 'n', 'a', 'v', 'r', 'o', 'm', 'a', 'g', 'i', 'c', '1', '0'}
+
+// ErrWriterClosed is returned when writing to a Writer after Close has been called.
+var ErrWriterClosed = errors.New("Writer closed")
+
+// A WriterOption changes how a Writer writes the file
+type WriterOption func(*writerConfig)
+
+type writerConfig struct {
+	syncMarker avro.Sync
+	randomSync bool
+	// Set if the RandomSync or SyncMarker option was given
+	syncSet      bool
+	metadata     map[string][]byte
+	deflateLevel int
+	blockSize    int
+	onBlock      func(BlockInfo)
+}
+
+// BlockInfo describes a block written to a file
+type BlockInfo struct {
+	// The offset of the start of the block in the file, and its size including the sync marker
+	Offset, Size int64
+	// The number of records in the block
+	Records int64
+}
+
+// RandomSync makes the Writer generate a random sync marker for the file, as the Avro spec requires
+// for files which are split on their sync markers. By default every file has the same fixed sync marker.
+func RandomSync() WriterOption {
+	return func(c *writerConfig) {
+		c.randomSync = true
+		c.syncSet = true
+	}
+}
+
+// SyncMarker sets the sync marker written after the header and every block
+func SyncMarker(sync avro.Sync) WriterOption {
+	return func(c *writerConfig) {
+		c.syncMarker = sync
+		c.randomSync = false
+		c.syncSet = true
+	}
+}
+
+// Metadata adds an entry to the metadata in the file header. Keys starting with "avro." are reserved by the spec,
+// and NewWriter returns an error if they're used.
+func Metadata(key string, value []byte) WriterOption {
+	return func(c *writerConfig) {
+		c.metadata[key] = value
+	}
+}
+
+// DeflateLevel sets the compression level used by the Deflate codec, from flate.BestSpeed to flate.BestCompression.
+// The default is flate.DefaultCompression.
+func DeflateLevel(level int) WriterOption {
+	return func(c *writerConfig) {
+		c.deflateLevel = level
+	}
+}
+
+// BlockSize makes the Writer flush a block once the encoded records buffered for it reach size bytes, before
+// compression. This bounds the memory used by blocks of large records. Blocks are still flushed after
+// recordsPerBlock records, unless recordsPerBlock is zero or less, in which case only their size is limited.
+func BlockSize(size int) WriterOption {
+	return func(c *writerConfig) {
+		c.blockSize = size
+	}
+}
+
+// OnBlock calls f after each block is written to the file, whether it's flushed by WriteRecord, Flush or Close
+// or written by WriteBlock, so the blocks can be recorded in an external index.
+func OnBlock(f func(BlockInfo)) WriterOption {
+	return func(c *writerConfig) {
+		c.onBlock = f
+	}
+}
+
+func newWriterConfig(opts []WriterOption) (writerConfig, error) {
+	c := writerConfig{
+		syncMarker:   defaultSyncMarker,
+		metadata:     make(map[string][]byte),
+		deflateLevel: flate.DefaultCompression,
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+	for key := range c.metadata {
+		if strings.HasPrefix(key, "avro.") {
+			return c, fmt.Errorf("Metadata key %q is reserved", key)
+		}
+	}
+	if c.blockSize < 0 {
+		return c, fmt.Errorf("Invalid block size %v", c.blockSize)
+	}
+	if c.randomSync {
+		if _, err := rand.Read(c.syncMarker[:]); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
 }
 
 // Writer wraps an io.Writer and writes the file and block-level framing required for an OCF file.
 // You can create a Writer for a given struct by calling the generated method `New<RecordType>Writer`.
 type Writer struct {
-	writer           io.Writer
+	writer           *countingWriter
 	syncMarker       [16]byte
 	codec            Codec
 	recordsPerBlock  int64
 	blockBuffer      *bytes.Buffer
-	compressedWriter io.Writer
+	compress         Compressor
 	nextBlockRecords int64
+	metadata         map[string][]byte
+	schema           string
+	closed           bool
+	blockSize        int
+	onBlock          func(BlockInfo)
 }
 
 //  Create a new Writer wrapping the provided io.Writer with the given Codec and number of records per block.
@@ -45,45 +155,69 @@ type Writer struct {
 //  You must call Flush on the Writer before closing the underlying io.Writer, to ensure the final block is written.
 //  A schema string must be passed to ensure that a correct header is written even if no records are written. This
 //  is required to produce valid empty Avro container files.
-func NewWriter(writer io.Writer, codec Codec, recordsPerBlock int64, schema string) (*Writer, error) {
+//  Options can set the sync marker, add metadata to the header, set the compression level and limit the size of blocks.
+func NewWriter(writer io.Writer, codec Codec, recordsPerBlock int64, schema string, opts ...WriterOption) (*Writer, error) {
+	config, err := newWriterConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	avroWriter, err := newWriter(writer, codec, recordsPerBlock, schema, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = avroWriter.writeHeader(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return avroWriter, nil
+}
+
+// newWriter returns a Writer which writes blocks to writer, without writing the header
+func newWriter(writer io.Writer, codec Codec, recordsPerBlock int64, schema string, config writerConfig) (*Writer, error) {
 	blockBytes := make([]byte, 0)
 	blockBuffer := bytes.NewBuffer(blockBytes)
 
 	avroWriter := &Writer{
-		writer:          writer,
-		syncMarker:      [16]byte{'g', 'o', 'g', 'e', 'n', 'a', 'v', 'r', 'o', 'm', 'a', 'g', 'i', 'c', '1', '0'},
+		writer:          &countingWriter{w: writer},
+		syncMarker:      config.syncMarker,
 		codec:           codec,
 		recordsPerBlock: recordsPerBlock,
 		blockBuffer:     blockBuffer,
+		metadata:        config.metadata,
+		schema:          schema,
+		blockSize:       config.blockSize,
+		onBlock:         config.onBlock,
 	}
-	var err error
-	if codec == Deflate {
-		avroWriter.compressedWriter, err = flate.NewWriter(avroWriter.blockBuffer, flate.DefaultCompression)
-		if err != nil {
-			return nil, err
-		}
-	} else if codec == Snappy {
-		avroWriter.compressedWriter = newSnappyWriter(avroWriter.blockBuffer)
-	} else {
-		avroWriter.compressedWriter = avroWriter.blockBuffer
-	}
-
-	err = avroWriter.writeHeader(schema)
+	codecFuncs, err := lookupCodec(codec)
 	if err != nil {
 		return nil, err
 	}
-
+	avroWriter.compress = codecFuncs.compress
+	if codec == Deflate && config.deflateLevel != flate.DefaultCompression {
+		// Check the level is valid before any records are written
+		if _, err := flate.NewWriter(ioutil.Discard, config.deflateLevel); err != nil {
+			return nil, err
+		}
+		avroWriter.compress = deflateCompressor(config.deflateLevel)
+	}
 	return avroWriter, nil
 }
 
 func (avroWriter *Writer) writeHeader(schema string) error {
+	meta := map[string][]byte{
+		"avro.schema": []byte(schema),
+		"avro.codec":  []byte(avroWriter.codec),
+	}
+	for key, value := range avroWriter.metadata {
+		meta[key] = value
+	}
 	header := &avro.AvroContainerHeader{
 		Magic: [4]byte{'O', 'b', 'j', 1},
-		Meta: map[string][]byte{
-			"avro.schema": []byte(schema),
-			"avro.codec":  []byte(avroWriter.codec),
-		},
-		Sync: avroWriter.syncMarker,
+		Meta:  meta,
+		Sync:  avroWriter.syncMarker,
 	}
 	return header.Serialize(avroWriter.writer)
 }
@@ -92,17 +226,19 @@ func (avroWriter *Writer) writeHeader(schema string) error {
 //  fulfill the AvroRecord interface. Note that all records in a given container file
 //  must be of the same Avro type.
 func (avroWriter *Writer) WriteRecord(record AvroRecord) error {
+	if avroWriter.closed {
+		return ErrWriterClosed
+	}
 	var err error
-	// Serialize the new record into the compressed writer
-	err = record.Serialize(avroWriter.compressedWriter)
+	// Serialize the new record into the block buffer, which is compressed when the block is flushed
+	err = record.Serialize(avroWriter.blockBuffer)
 	if err != nil {
 		return err
 	}
 	avroWriter.nextBlockRecords += 1
 
-	// If the block if full, flush and reset the compressed writer,
-	// write the header and the block contents
-	if avroWriter.nextBlockRecords >= avroWriter.recordsPerBlock {
+	// If the block if full, compress it and write the header and the block contents
+	if avroWriter.blockFull() {
 		return avroWriter.Flush()
 	}
 
@@ -111,32 +247,149 @@ func (avroWriter *Writer) WriteRecord(record AvroRecord) error {
 
 //  Write the current block to the file if it has been filled.  It is
 //  best-practise to always call this before the underlying io.Writer is closed.
+//  The OnBlock option reports the offset and record count of each block written.
 func (avroWriter *Writer) Flush() error {
+	_, err := avroWriter.FlushBlock()
+	return err
+}
+
+//  Write the current block to the file like Flush, and return its offset, size and record count,
+//  which are zero if there were no records to write.
+func (avroWriter *Writer) FlushBlock() (BlockInfo, error) {
+	if avroWriter.closed {
+		return BlockInfo{}, ErrWriterClosed
+	}
 	if avroWriter.nextBlockRecords == 0 {
-		return nil
+		return BlockInfo{}, nil
 	}
 
 	// Write out all of the buffered records as a new block
 	// Must be called before closing to ensure the last block is written
-	if fwWriter, ok := avroWriter.compressedWriter.(CloseableResettableWriter); ok {
-		fwWriter.Close()
-		fwWriter.Reset(avroWriter.blockBuffer)
+	compressed, err := avroWriter.compress(avroWriter.blockBuffer.Bytes())
+	if err != nil {
+		return BlockInfo{}, err
+	}
+	block := &avro.AvroContainerBlock{
+		NumRecords:  avroWriter.nextBlockRecords,
+		RecordBytes: compressed,
+		Sync:        avroWriter.syncMarker,
+	}
+	info, err := avroWriter.writeBlock(block)
+	if err != nil {
+		return BlockInfo{}, err
 	}
 
-	if avroWriter.nextBlockRecords > 0 {
-		block := &avro.AvroContainerBlock{
-			NumRecords:  avroWriter.nextBlockRecords,
-			RecordBytes: avroWriter.blockBuffer.Bytes(),
-			Sync:        avroWriter.syncMarker,
+	avroWriter.blockBuffer.Reset()
+	avroWriter.nextBlockRecords = 0
+
+	return info, nil
+}
+
+// blockFull returns true once the buffered block has reached the record count or size it's flushed at
+func (avroWriter *Writer) blockFull() bool {
+	if avroWriter.blockSize > 0 {
+		if avroWriter.blockBuffer.Len() >= avroWriter.blockSize {
+			return true
 		}
-		err := block.Serialize(avroWriter.writer)
-		if err != nil {
-			return err
+		if avroWriter.recordsPerBlock <= 0 {
+			return false
 		}
 	}
+	return avroWriter.nextBlockRecords >= avroWriter.recordsPerBlock
+}
+
+// writeBlock writes a block to the file, reports it to the OnBlock callback and returns where it was written
+func (avroWriter *Writer) writeBlock(block *avro.AvroContainerBlock) (BlockInfo, error) {
+	offset := avroWriter.writer.n
+	if err := block.Serialize(avroWriter.writer); err != nil {
+		return BlockInfo{}, err
+	}
+	info := BlockInfo{Offset: offset, Size: avroWriter.writer.n - offset, Records: block.NumRecords}
+	if avroWriter.onBlock != nil {
+		avroWriter.onBlock(info)
+	}
+	return info, nil
+}
 
-	avroWriter.blockBuffer.Reset()
-	avroWriter.nextBlockRecords = 0
+//  Flush the last block and close the Writer, so later writes return ErrWriterClosed.
+//  The underlying io.Writer isn't closed. Calling Close again has no effect.
+func (avroWriter *Writer) Close() error {
+	if avroWriter.closed {
+		return nil
+	}
+	err := avroWriter.Flush()
+	avroWriter.closed = true
+	return err
+}
+
+//  Write a block of records which are already encoded and compressed with the Writer's codec, such as one
+//  returned by Reader.ReadBlock. Any records written with WriteRecord are flushed first, so the order of the
+//  records is kept. The block is written with the Writer's sync marker.
+func (avroWriter *Writer) WriteBlock(block *avro.AvroContainerBlock) error {
+	if err := avroWriter.Flush(); err != nil {
+		return err
+	}
+	raw := &avro.AvroContainerBlock{
+		NumRecords:  block.NumRecords,
+		RecordBytes: block.RecordBytes,
+		Sync:        avroWriter.syncMarker,
+	}
+	_, err := avroWriter.writeBlock(raw)
+	return err
+}
+
+//  Copy every remaining block read by reader into the file without decoding the records, and return the number
+//  of records copied. The file being read must use the same codec as the Writer, and its schema must have the
+//  same canonical form as the Writer's.
+func (avroWriter *Writer) CopyBlocks(reader *Reader) (int64, error) {
+	if reader.Codec() != avroWriter.codec {
+		return 0, fmt.Errorf("Can't copy blocks compressed with %q to a file compressed with %q", reader.Codec(), avroWriter.codec)
+	}
+	if err := checkSameSchema([]byte(avroWriter.schema), reader.AvroContainerSchema()); err != nil {
+		return 0, err
+	}
+
+	var records int64
+	for {
+		block, err := reader.ReadBlock()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		if err := avroWriter.WriteBlock(block); err != nil {
+			return records, err
+		}
+		records += block.NumRecords
+	}
+}
 
+// checkSameSchema returns an error unless the schemas have the same canonical form,
+// so records written with one can be copied into a file with the other
+func checkSameSchema(expected, actual []byte) error {
+	expectedFingerprint, err := schema.Fingerprint(expected)
+	if err != nil {
+		return err
+	}
+	actualFingerprint, err := schema.Fingerprint(actual)
+	if err != nil {
+		return err
+	}
+	if expectedFingerprint != actualFingerprint {
+		return fmt.Errorf("Can't copy blocks written with schema %s to a file with schema %s", actual, expected)
+	}
 	return nil
 }
+
+// countingWriter counts the bytes written through it, to track the offset in the file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -1,18 +1,20 @@
 // Code generated by github.com/clear-street/gogen-avro. DO NOT EDIT.
-/*
- * SOURCE:
- *     example.avsc
- */
 
 package avro
 
 import (
-	"io"
-
+	"fmt"
 	"github.com/clear-street/gogen-avro/compiler"
 	"github.com/clear-street/gogen-avro/container"
 	"github.com/clear-street/gogen-avro/vm"
 	"github.com/clear-street/gogen-avro/vm/types"
+	"io"
+)
+
+const (
+	DemoSchemaName          = "DemoSchema"
+	DemoSchemaNamespace     = ""
+	DemoSchemaQualifiedName = "DemoSchema"
 )
 
 type DemoSchema struct {
@@ -23,15 +25,24 @@ type DemoSchema struct {
 	BytesField  []byte
 }
 
-func NewDemoSchemaWriter(writer io.Writer, codec container.Codec, recordsPerBlock int64) (*container.Writer, error) {
+func NewDemoSchemaAppendWriter(file io.ReadWriteSeeker, recordsPerBlock int64, opts ...container.WriterOption) (*container.Writer, error) {
 	str := &DemoSchema{}
-	return container.NewWriter(writer, codec, recordsPerBlock, str.Schema())
+	return container.NewAppendWriter(file, recordsPerBlock, str.Schema(), opts...)
 }
 
-func DeserializeDemoSchema(r io.Reader) (*DemoSchema, error) {
+func NewDemoSchemaWriter(writer io.Writer, codec container.Codec, recordsPerBlock int64, opts ...container.WriterOption) (*container.Writer, error) {
+	str := &DemoSchema{}
+	return container.NewWriter(writer, codec, recordsPerBlock, str.Schema(), opts...)
+}
+
+func DeserializeDemoSchema(r io.Reader, schema string) (*DemoSchema, error) {
 	t := NewDemoSchema()
 
-	deser, err := compiler.CompileSchemaBytes([]byte(t.Schema()), []byte(t.Schema()))
+	schemaBytes := []byte(schema)
+	if schema == "" {
+		schemaBytes = []byte(t.Schema())
+	}
+	deser, err := compiler.CompileOptimizedSchemaBytes(schemaBytes, []byte(t.Schema()))
 	if err != nil {
 		return nil, err
 	}
@@ -41,64 +52,189 @@ func DeserializeDemoSchema(r io.Reader) (*DemoSchema, error) {
 }
 
 func NewDemoSchema() *DemoSchema {
-	return &DemoSchema{}
+	v := &DemoSchema{}
+
+	return v
+}
+
+func (r *DemoSchema) QualifiedName() string {
+	return "" + "." + "DemoSchema"
 }
 
 func (r *DemoSchema) Schema() string {
 	return "{\"fields\":[{\"name\":\"IntField\",\"type\":\"int\"},{\"name\":\"DoubleField\",\"type\":\"double\"},{\"name\":\"StringField\",\"type\":\"string\"},{\"name\":\"BoolField\",\"type\":\"boolean\"},{\"name\":\"BytesField\",\"type\":\"bytes\"}],\"name\":\"DemoSchema\",\"type\":\"record\"}"
 }
 
+func (r *DemoSchema) SchemaName() string {
+	return "DemoSchema"
+}
+
 func (r *DemoSchema) Serialize(w io.Writer) error {
-	return writeDemoSchema(r, w)
-}
-
-func (_ *DemoSchema) DeserializeBoolean(v bool)   { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeInt(v int32)      { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeLong(v int64)     { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeFloat(v float32)  { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeBytes(v []byte)   { panic("Unsupported operation") }
-func (_ *DemoSchema) DeserializeString(v string)  { panic("Unsupported operation") }
-func (_ *DemoSchema) SetUnionElem(v int64)        { panic("Unsupported operation") }
-func (_ *DemoSchema) SetDefault(i int)            { panic("Unsupported operation") }
-func (r *DemoSchema) Get(i int) types.Field {
+	return WriteDemoSchema(r, w)
+}
+
+func (_ *DemoSchema) DeserializeBoolean(v bool) error   { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeInt(v int32) error      { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeLong(v int64) error     { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeFloat(v float32) error  { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeBytes(v []byte) error   { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) DeserializeString(v string) error  { return types.ErrUnsupportedOperation }
+func (_ *DemoSchema) SetUnionElem(v int64) error        { return types.ErrUnsupportedOperation }
+func (r *DemoSchema) Get(i int) (types.Field, error) {
 	switch i {
 	case 0:
-		return (*types.Int)(&r.IntField)
-		break
+		return (*types.Int)(&r.IntField), nil
 	case 1:
-		return (*types.Double)(&r.DoubleField)
-		break
+		return (*types.Double)(&r.DoubleField), nil
 	case 2:
-		return (*types.String)(&r.StringField)
-		break
+		return (*types.String)(&r.StringField), nil
 	case 3:
-		return (*types.Boolean)(&r.BoolField)
-		break
+		return (*types.Boolean)(&r.BoolField), nil
 	case 4:
-		return (*types.Bytes)(&r.BytesField)
-		break
+		return (*types.Bytes)(&r.BytesField), nil
+
+	}
+	return nil, types.ErrUnknownFieldIndex
+}
+func (r *DemoSchema) SetDefault(i int) error {
+	switch i {
+
+	}
+	return types.ErrUnknownFieldIndex
+}
+func (_ *DemoSchema) AppendMap(key string) (types.Field, error) {
+	return nil, types.ErrUnsupportedOperation
+}
+func (_ *DemoSchema) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *DemoSchema) Finalize() error                   { return nil }
+
+type DemoSchemaParallelReader struct {
+	r       *container.ParallelReader
+	records []*DemoSchema
+}
+
+// NewDemoSchemaParallelReader reads an OCF file, decompressing and decoding its blocks on the given number of workers
+// (GOMAXPROCS if it's zero). Records are still returned in the order they were written.
+// The reader must be closed to stop the workers.
+func NewDemoSchemaParallelReader(r io.Reader, workers int, opts ...container.ReaderOption) (*DemoSchemaParallelReader, error) {
+	containerReader, err := container.NewReader(r, opts...)
+	if err != nil {
+		return nil, err
+	}
 
+	t := NewDemoSchema()
+	deser, err := compiler.CompileOptimizedSchemaBytes([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each worker reuses one evaluator for all of its blocks
+	newDecoder := func() container.BlockDecoder {
+		evaluator := vm.NewEvaluator(deser)
+		return func(records []byte, count int64) (interface{}, error) {
+			var block []*DemoSchema
+			for i := int64(0); i < count; i++ {
+				t := NewDemoSchema()
+				n, err := evaluator.EvalBytes(records, t)
+				if err != nil {
+					return nil, err
+				}
+				records = records[n:]
+				block = append(block, t)
+			}
+			if len(records) > 0 {
+				return nil, fmt.Errorf("Block has %v bytes left after %v records", len(records), count)
+			}
+			return block, nil
+		}
 	}
-	panic("Unknown field index")
+	return &DemoSchemaParallelReader{
+		r: container.NewParallelReader(containerReader, workers, newDecoder),
+	}, nil
+}
+
+// Read returns the next record in the file, or io.EOF once every record has been read
+func (r *DemoSchemaParallelReader) Read() (*DemoSchema, error) {
+	for len(r.records) == 0 {
+		block, err := r.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		r.records = block.([]*DemoSchema)
+	}
+	t := r.records[0]
+	r.records = r.records[1:]
+	return t, nil
+}
+
+// Close stops the workers decoding blocks ahead of Read
+func (r *DemoSchemaParallelReader) Close() error {
+	return r.r.Close()
+}
+
+// Metadata returns a copy of the metadata in the file header, including the avro.schema and avro.codec entries
+func (r *DemoSchemaParallelReader) Metadata() map[string][]byte {
+	return r.r.Metadata()
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (r *DemoSchemaParallelReader) Codec() container.Codec {
+	return r.r.Codec()
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (r *DemoSchemaParallelReader) SyncMarker() [16]byte {
+	return r.r.SyncMarker()
 }
-func (_ *DemoSchema) AppendMap(key string) types.Field { panic("Unsupported operation") }
-func (_ *DemoSchema) AppendArray() types.Field         { panic("Unsupported operation") }
-func (_ *DemoSchema) Finalize()                        {}
 
 type DemoSchemaReader struct {
-	r io.Reader
+	r *container.Reader
 	p *vm.Program
 }
 
-func NewDemoSchemaReader(r io.Reader) (*DemoSchemaReader, error) {
-	containerReader, err := container.NewReader(r)
+func NewDemoSchemaReader(r io.Reader, opts ...container.ReaderOption) (*DemoSchemaReader, error) {
+	containerReader, err := container.NewReader(r, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return newDemoSchemaReader(containerReader)
+}
 
+// NewDemoSchemaReaderForSplit reads the records in the blocks of an OCF file which belong to the byte range [start, end),
+// so workers can each read part of one file. See container.NewSplitReader for how blocks are assigned to ranges.
+func NewDemoSchemaReaderForSplit(r io.ReaderAt, start, end int64, opts ...container.ReaderOption) (*DemoSchemaReader, error) {
+	containerReader, err := container.NewSplitReader(r, start, end, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newDemoSchemaReader(containerReader)
+}
+
+// NewDemoSchemaReaderAt reads an OCF file starting from a position returned by Position. Only the block of the position
+// is read, and the records before it in the block are skipped without building a struct for each of them.
+func NewDemoSchemaReaderAt(r io.ReadSeeker, pos container.Position, opts ...container.ReaderOption) (*DemoSchemaReader, error) {
+	containerReader, err := container.NewReaderAt(r, pos, opts...)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newDemoSchemaReader(containerReader)
+	if err != nil {
+		return nil, err
+	}
+	skip := vm.NewEvaluator(reader.p)
+	for i := int64(0); i < pos.Record(); i++ {
+		if err := skip.Eval(containerReader, types.Discard{}); err != nil {
+			return nil, err
+		}
+		containerReader.RecordRead()
+	}
+	return reader, nil
+}
+
+func newDemoSchemaReader(containerReader *container.Reader) (*DemoSchemaReader, error) {
 	t := NewDemoSchema()
-	deser, err := compiler.CompileSchemaBytes([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
+	deser, err := compiler.CompileOptimizedSchemaBytes([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
 	if err != nil {
 		return nil, err
 	}
@@ -112,5 +248,29 @@ func NewDemoSchemaReader(r io.Reader) (*DemoSchemaReader, error) {
 func (r *DemoSchemaReader) Read() (*DemoSchema, error) {
 	t := NewDemoSchema()
 	err := vm.Eval(r.r, r.p, t)
+	if err == nil {
+		r.r.RecordRead()
+	}
 	return t, err
 }
+
+// Position returns the position of the next record to be read, which can be saved to resume reading
+// from it later with NewDemoSchemaReaderAt
+func (r *DemoSchemaReader) Position() container.Position {
+	return r.r.Position()
+}
+
+// Metadata returns a copy of the metadata in the file header, including the avro.schema and avro.codec entries
+func (r *DemoSchemaReader) Metadata() map[string][]byte {
+	return r.r.Metadata()
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (r *DemoSchemaReader) Codec() container.Codec {
+	return r.r.Codec()
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (r *DemoSchemaReader) SyncMarker() [16]byte {
+	return r.r.SyncMarker()
+}
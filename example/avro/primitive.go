@@ -1,8 +1,4 @@
 // Code generated by github.com/clear-street/gogen-avro. DO NOT EDIT.
-/*
- * SOURCE:
- *     example.avsc
- */
 
 package avro
 
@@ -20,6 +16,32 @@ type StringWriter interface {
 	WriteString(string) (int, error)
 }
 
+func WriteDemoSchema(r *DemoSchema, w io.Writer) error {
+	var err error
+	err = writeInt(r.IntField, w)
+	if err != nil {
+		return err
+	}
+	err = writeDouble(r.DoubleField, w)
+	if err != nil {
+		return err
+	}
+	err = writeString(r.StringField, w)
+	if err != nil {
+		return err
+	}
+	err = writeBool(r.BoolField, w)
+	if err != nil {
+		return err
+	}
+	err = writeBytes(r.BytesField, w)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func encodeFloat(w io.Writer, byteCount int, bits uint64) error {
 	var err error
 	var bb []byte
@@ -122,32 +144,6 @@ func writeBytes(r []byte, w io.Writer) error {
 	return err
 }
 
-func writeDemoSchema(r *DemoSchema, w io.Writer) error {
-	var err error
-	err = writeInt(r.IntField, w)
-	if err != nil {
-		return err
-	}
-	err = writeDouble(r.DoubleField, w)
-	if err != nil {
-		return err
-	}
-	err = writeString(r.StringField, w)
-	if err != nil {
-		return err
-	}
-	err = writeBool(r.BoolField, w)
-	if err != nil {
-		return err
-	}
-	err = writeBytes(r.BytesField, w)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func writeDouble(r float64, w io.Writer) error {
 	bits := uint64(math.Float64bits(r))
 	const byteCount = 8
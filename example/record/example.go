@@ -24,7 +24,7 @@ func main() {
 	demoStruct.Serialize(&buf)
 
 	// Deserialize the byte buffer back into a struct
-	newDemoStruct, err := avro.DeserializeDemoSchema(&buf)
+	newDemoStruct, err := avro.DeserializeDemoSchema(&buf, "")
 	if err != nil {
 		fmt.Printf("Error deserializing struct: %v\n", err)
 		return
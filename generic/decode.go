@@ -0,0 +1,385 @@
+package generic
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/schema"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+)
+
+// Decoder reads data written with one schema into generic values for another.
+// The program is compiled once with compiler.Compile, so schema evolution works the same way
+// as for generated types. A Decoder can be used from multiple goroutines.
+type Decoder struct {
+	program *vm.Program
+	reader  schema.AvroType
+}
+
+// NewDecoder compiles a Decoder for data written with the writer schema.
+// If reader is nil, values are read with the writer schema.
+func NewDecoder(writer, reader schema.AvroType) (*Decoder, error) {
+	if reader == nil {
+		reader = writer
+	}
+	program, err := compiler.Compile(writer, reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{program: program, reader: reader}, nil
+}
+
+// NewDecoderSchemaBytes parses the JSON writer and reader schemas and compiles a Decoder.
+// If reader is empty, values are read with the writer schema.
+func NewDecoderSchemaBytes(writer, reader []byte) (*Decoder, error) {
+	writerType, err := ParseSchema(writer)
+	if err != nil {
+		return nil, err
+	}
+	readerType := writerType
+	if len(reader) > 0 {
+		if readerType, err = ParseSchema(reader); err != nil {
+			return nil, err
+		}
+	}
+	return NewDecoder(writerType, readerType)
+}
+
+// ParseSchema parses a JSON Avro schema and resolves the references to named types in it
+func ParseSchema(s []byte) (schema.AvroType, error) {
+	ns := schema.NewNamespace(false)
+	sType, err := ns.TypeForSchema(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = sType.ResolveReferences(ns); err != nil {
+		return nil, err
+	}
+	return sType, nil
+}
+
+// Schema returns the reader schema values are decoded with
+func (d *Decoder) Schema() schema.AvroType {
+	return d.reader
+}
+
+// Decode reads a single datum from r, with records decoded as a map[string]interface{}
+func (d *Decoder) Decode(r io.Reader, opts ...vm.Option) (interface{}, error) {
+	var result interface{}
+	err := vm.Eval(r, d.program, d.newTarget(&result, false), opts...)
+	return result, err
+}
+
+// DecodeBytes reads a single datum from the start of buf with vm.EvalBytes, with records decoded
+// as a map[string]interface{}, and returns the number of bytes consumed.
+func (d *Decoder) DecodeBytes(buf []byte, opts ...vm.Option) (interface{}, int, error) {
+	var result interface{}
+	n, err := vm.EvalBytes(buf, d.program, d.newTarget(&result, false), opts...)
+	return result, n, err
+}
+
+// DecodeRecord reads a single record from r, with records decoded as a *Record.
+// The reader schema must be a record.
+func (d *Decoder) DecodeRecord(r io.Reader, opts ...vm.Option) (*Record, error) {
+	var result interface{}
+	target, err := d.newRecordTarget(&result)
+	if err != nil {
+		return nil, err
+	}
+	err = vm.Eval(r, d.program, target, opts...)
+	return result.(*Record), err
+}
+
+// DecodeRecordBytes reads a single record from the start of buf with vm.EvalBytes, with records
+// decoded as a *Record, and returns the number of bytes consumed. The reader schema must be a record.
+func (d *Decoder) DecodeRecordBytes(buf []byte, opts ...vm.Option) (*Record, int, error) {
+	var result interface{}
+	target, err := d.newRecordTarget(&result)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := vm.EvalBytes(buf, d.program, target, opts...)
+	return result.(*Record), n, err
+}
+
+func (d *Decoder) newTarget(result *interface{}, typed bool) types.Field {
+	return newField(d.reader, func(v interface{}) { *result = v }, typed)
+}
+
+func (d *Decoder) newRecordTarget(result *interface{}) (types.Field, error) {
+	if ref, ok := d.reader.(*schema.Reference); ok {
+		if _, ok := ref.Def.(*schema.RecordDefinition); ok {
+			return d.newTarget(result, true), nil
+		}
+	}
+	return nil, fmt.Errorf("Reader schema %v is not a record", d.reader.Name())
+}
+
+// newField returns the target for a value of type t, which passes the value to set as soon as it's created.
+// Records, arrays and maps are passed to set before their contents are decoded.
+func newField(t schema.AvroType, set func(interface{}), typed bool) types.Field {
+	switch s := t.(type) {
+	case *schema.NullField:
+		set(nil)
+	case *schema.ArrayField:
+		return newArrayField(s, set, typed)
+	case *schema.MapField:
+		return newMapField(s, set, typed)
+	case *schema.UnionField:
+		return &unionField{union: s, set: set, typed: typed}
+	case *schema.Reference:
+		switch def := s.Def.(type) {
+		case *schema.RecordDefinition:
+			return newRecordField(def, set, typed)
+		case *schema.EnumDefinition:
+			return &enumField{def: def, set: set}
+		}
+	}
+	return &primitiveField{t: t, set: set}
+}
+
+// unsupportedField implements every types.Field method by returning ErrUnsupportedOperation,
+// for the targets below to override
+type unsupportedField struct{}
+
+func (_ unsupportedField) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) DeserializeInt(v int32) error    { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) DeserializeLong(v int64) error   { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) DeserializeFloat(v float32) error {
+	return types.ErrUnsupportedOperation
+}
+func (_ unsupportedField) DeserializeDouble(v float64) error {
+	return types.ErrUnsupportedOperation
+}
+func (_ unsupportedField) DeserializeBytes(v []byte) error  { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) Get(i int) (types.Field, error)   { return nil, types.ErrUnsupportedOperation }
+func (_ unsupportedField) SetDefault(i int) error           { return types.ErrUnsupportedOperation }
+func (_ unsupportedField) AppendArray() (types.Field, error) {
+	return nil, types.ErrUnsupportedOperation
+}
+func (_ unsupportedField) AppendMap(key string) (types.Field, error) {
+	return nil, types.ErrUnsupportedOperation
+}
+func (_ unsupportedField) Finalize() error { return nil }
+
+// primitiveField is the target for a primitive or fixed value, and accepts the promotions allowed by the spec
+type primitiveField struct {
+	unsupportedField
+	t   schema.AvroType
+	set func(interface{})
+}
+
+func (p *primitiveField) DeserializeBoolean(v bool) error {
+	if _, ok := p.t.(*schema.BoolField); ok {
+		p.set(v)
+		return nil
+	}
+	return types.ErrUnsupportedOperation
+}
+
+func (p *primitiveField) DeserializeInt(v int32) error {
+	switch p.t.(type) {
+	case *schema.IntField:
+		p.set(v)
+	case *schema.LongField:
+		p.set(int64(v))
+	case *schema.FloatField:
+		p.set(float32(v))
+	case *schema.DoubleField:
+		p.set(float64(v))
+	default:
+		return types.ErrUnsupportedOperation
+	}
+	return nil
+}
+
+func (p *primitiveField) DeserializeLong(v int64) error {
+	switch p.t.(type) {
+	case *schema.LongField:
+		p.set(v)
+	case *schema.FloatField:
+		p.set(float32(v))
+	case *schema.DoubleField:
+		p.set(float64(v))
+	default:
+		return types.ErrUnsupportedOperation
+	}
+	return nil
+}
+
+func (p *primitiveField) DeserializeFloat(v float32) error {
+	switch p.t.(type) {
+	case *schema.FloatField:
+		p.set(v)
+	case *schema.DoubleField:
+		p.set(float64(v))
+	default:
+		return types.ErrUnsupportedOperation
+	}
+	return nil
+}
+
+func (p *primitiveField) DeserializeDouble(v float64) error {
+	if _, ok := p.t.(*schema.DoubleField); ok {
+		p.set(v)
+		return nil
+	}
+	return types.ErrUnsupportedOperation
+}
+
+func (p *primitiveField) DeserializeBytes(v []byte) error {
+	switch p.t.(type) {
+	case *schema.BytesField, *schema.Reference:
+		// The only reference with a primitiveField target is a fixed
+		p.set(v)
+	case *schema.StringField:
+		p.set(string(v))
+	default:
+		return types.ErrUnsupportedOperation
+	}
+	return nil
+}
+
+func (p *primitiveField) DeserializeString(v string) error {
+	switch p.t.(type) {
+	case *schema.StringField:
+		p.set(v)
+	case *schema.BytesField:
+		p.set([]byte(v))
+	default:
+		return types.ErrUnsupportedOperation
+	}
+	return nil
+}
+
+// enumField is the target for an enum, which is decoded as the symbol
+type enumField struct {
+	unsupportedField
+	def *schema.EnumDefinition
+	set func(interface{})
+}
+
+func (e *enumField) DeserializeInt(v int32) error {
+	symbols := e.def.Symbols()
+	if v < 0 || int(v) >= len(symbols) {
+		return fmt.Errorf("Invalid index %v for enum %v", v, e.def.Name())
+	}
+	e.set(symbols[v])
+	return nil
+}
+
+type recordField struct {
+	unsupportedField
+	record *Record
+	// Set for a record decoded as a map, whose fields are written directly to the map
+	fields map[string]interface{}
+	typed  bool
+}
+
+func newRecordField(def *schema.RecordDefinition, set func(interface{}), typed bool) *recordField {
+	r := &recordField{record: NewRecord(def), typed: typed}
+	if typed {
+		set(r.record)
+	} else {
+		r.fields = make(map[string]interface{}, len(r.record.Values))
+		set(r.fields)
+	}
+	return r
+}
+
+// setter returns the function which sets the field with index i
+func (r *recordField) setter(i int) func(interface{}) {
+	if r.typed {
+		return func(v interface{}) { r.record.Values[i] = v }
+	}
+	name := r.record.Def.Fields()[i].Name()
+	return func(v interface{}) { r.fields[name] = v }
+}
+
+func (r *recordField) Get(i int) (types.Field, error) {
+	fields := r.record.Def.Fields()
+	if i < 0 || i >= len(fields) {
+		return nil, types.ErrUnknownFieldIndex
+	}
+	return newField(fields[i].Type(), r.setter(i), r.typed), nil
+}
+
+func (r *recordField) SetDefault(i int) error {
+	fields := r.record.Def.Fields()
+	if i < 0 || i >= len(fields) {
+		return types.ErrUnknownFieldIndex
+	}
+	v, err := defaultValue(fields[i].Type(), fields[i].Default(), r.typed)
+	if err != nil {
+		return err
+	}
+	r.setter(i)(v)
+	return nil
+}
+
+type arrayField struct {
+	unsupportedField
+	array *schema.ArrayField
+	items []interface{}
+	set   func(interface{})
+	typed bool
+}
+
+func newArrayField(array *schema.ArrayField, set func(interface{}), typed bool) *arrayField {
+	a := &arrayField{array: array, items: make([]interface{}, 0), set: set, typed: typed}
+	set(a.items)
+	return a
+}
+
+func (a *arrayField) AppendArray() (types.Field, error) {
+	i := len(a.items)
+	a.items = append(a.items, nil)
+	// The append may have moved the items, so pass the new slice to the parent
+	a.set(a.items)
+	return newField(a.array.ItemType(), func(v interface{}) { a.items[i] = v }, a.typed), nil
+}
+
+type mapField struct {
+	unsupportedField
+	m     *schema.MapField
+	items map[string]interface{}
+	typed bool
+}
+
+func newMapField(m *schema.MapField, set func(interface{}), typed bool) *mapField {
+	f := &mapField{m: m, items: make(map[string]interface{}), typed: typed}
+	set(f.items)
+	return f
+}
+
+func (m *mapField) AppendMap(key string) (types.Field, error) {
+	return newField(m.m.ItemType(), func(v interface{}) { m.items[key] = v }, m.typed), nil
+}
+
+// unionField is the target for a union in the reader schema, which is decoded as the value of the branch
+type unionField struct {
+	unsupportedField
+	union *schema.UnionField
+	set   func(interface{})
+	typed bool
+}
+
+// DeserializeLong is called with the index of the branch before it's entered
+func (u *unionField) DeserializeLong(v int64) error {
+	if v < 0 || v >= int64(len(u.union.AvroTypes())) {
+		return fmt.Errorf("Invalid index %v for union %v", v, u.union.Name())
+	}
+	return nil
+}
+
+func (u *unionField) Get(i int) (types.Field, error) {
+	branches := u.union.AvroTypes()
+	if i < 0 || i >= len(branches) {
+		return nil, types.ErrUnknownFieldIndex
+	}
+	return newField(branches[i], u.set, u.typed), nil
+}
@@ -0,0 +1,151 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const eventWriterSchema = `{"type": "record", "name": "Event", "fields": [
+	{"name": "id", "type": "int"},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+	{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+	{"name": "note", "type": ["null", "string"]},
+	{"name": "tags", "type": {"type": "array", "items": {"type": "record", "name": "Tag", "fields": [
+		{"name": "key", "type": "string"}
+	]}}},
+	{"name": "scores", "type": {"type": "map", "values": "double"}},
+	{"name": "dropped", "type": "string"}
+]}`
+
+// The reader promotes id to a long, drops a field and adds two with defaults
+const eventReaderSchema = `{"type": "record", "name": "Event", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+	{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+	{"name": "note", "type": ["null", "string"]},
+	{"name": "tags", "type": {"type": "array", "items": {"type": "record", "name": "Tag", "fields": [
+		{"name": "key", "type": "string"}
+	]}}},
+	{"name": "scores", "type": {"type": "map", "values": "double"}},
+	{"name": "added", "type": "int", "default": 7},
+	{"name": "extra", "type": {"type": "record", "name": "Extra", "fields": [
+		{"name": "x", "type": "string", "default": "d"}
+	]}, "default": {}}
+]}`
+
+func appendLong(b []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(b, scratch[:binary.PutVarint(scratch[:], v)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLong(b, int64(len(s))), s...)
+}
+
+func appendDouble(b []byte, f float64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+	return append(b, scratch[:]...)
+}
+
+// encodeEvent encodes an Event with the writer schema, with the note set if it's not empty
+func encodeEvent(note string) []byte {
+	b := appendLong(nil, 5)
+	b = appendLong(b, 1)
+	b = append(b, 'h', 'i')
+	if note == "" {
+		b = appendLong(b, 0)
+	} else {
+		b = appendString(appendLong(b, 1), note)
+	}
+	b = appendString(appendLong(b, 1), "k")
+	b = appendLong(b, 0)
+	b = appendDouble(appendString(appendLong(b, 1), "s"), 1.5)
+	b = appendLong(b, 0)
+	return appendString(b, "zz")
+}
+
+var eventFixture = map[string]interface{}{
+	"id":     int64(5),
+	"kind":   "B",
+	"hash":   []byte("hi"),
+	"note":   "n",
+	"tags":   []interface{}{map[string]interface{}{"key": "k"}},
+	"scores": map[string]interface{}{"s": 1.5},
+	"added":  int32(7),
+	"extra":  map[string]interface{}{"x": "d"},
+}
+
+func TestDecode(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(eventWriterSchema), []byte(eventReaderSchema))
+	assert.Nil(t, err)
+	buf := encodeEvent("n")
+
+	v, err := d.Decode(bytes.NewReader(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, eventFixture, v)
+
+	v, n, err := d.DecodeBytes(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, eventFixture, v)
+}
+
+func TestDecodeNullBranch(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(eventWriterSchema), nil)
+	assert.Nil(t, err)
+
+	v, err := d.Decode(bytes.NewReader(encodeEvent("")))
+	assert.Nil(t, err)
+	fields := v.(map[string]interface{})
+	assert.Contains(t, fields, "note")
+	assert.Nil(t, fields["note"])
+	assert.Equal(t, "zz", fields["dropped"])
+}
+
+func TestDecodeRecord(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(eventWriterSchema), []byte(eventReaderSchema))
+	assert.Nil(t, err)
+
+	record, err := d.DecodeRecord(bytes.NewReader(encodeEvent("n")))
+	assert.Nil(t, err)
+	assert.Equal(t, "Event", record.Def.AvroName().Name)
+	id, ok := record.Get("id")
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), id)
+	_, ok = record.Get("dropped")
+	assert.False(t, ok)
+
+	extra, _ := record.Get("extra")
+	assert.IsType(t, &Record{}, extra)
+	tags, _ := record.Get("tags")
+	assert.IsType(t, &Record{}, tags.([]interface{})[0])
+
+	assert.Equal(t, eventFixture, record.Map())
+}
+
+func TestDecodeRecordRequiresRecord(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(`"string"`), nil)
+	assert.Nil(t, err)
+
+	v, _, err := d.DecodeBytes(appendString(nil, "hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", v)
+
+	_, err = d.DecodeRecord(bytes.NewReader(appendString(nil, "hello")))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeInvalidEnum(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(eventWriterSchema), nil)
+	assert.Nil(t, err)
+
+	buf := encodeEvent("n")
+	buf[1] = 4
+	_, err = d.Decode(bytes.NewReader(buf))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,133 @@
+package generic
+
+import (
+	"fmt"
+
+	"github.com/clear-street/gogen-avro/schema"
+)
+
+// defaultValue converts a field default, as decoded from the JSON schema, to the Go value for the type.
+// Records are returned as a *Record if typed is set, and a map otherwise.
+func defaultValue(t schema.AvroType, v interface{}, typed bool) (interface{}, error) {
+	switch s := t.(type) {
+	case *schema.NullField:
+		if v != nil {
+			return nil, fmt.Errorf("Invalid default %v for null", v)
+		}
+		return nil, nil
+	case *schema.BoolField:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case *schema.IntField:
+		if n, ok := jsonNumber(v); ok {
+			return int32(n), nil
+		}
+	case *schema.LongField:
+		if n, ok := jsonNumber(v); ok {
+			return int64(n), nil
+		}
+	case *schema.FloatField:
+		if n, ok := jsonNumber(v); ok {
+			return float32(n), nil
+		}
+	case *schema.DoubleField:
+		if n, ok := jsonNumber(v); ok {
+			return n, nil
+		}
+	case *schema.StringField:
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+	case *schema.BytesField:
+		if str, ok := v.(string); ok {
+			return jsonBytes(str), nil
+		}
+	case *schema.ArrayField:
+		if items, ok := v.([]interface{}); ok {
+			values := make([]interface{}, len(items))
+			for i, item := range items {
+				var err error
+				if values[i], err = defaultValue(s.ItemType(), item, typed); err != nil {
+					return nil, err
+				}
+			}
+			return values, nil
+		}
+	case *schema.MapField:
+		if items, ok := v.(map[string]interface{}); ok {
+			values := make(map[string]interface{}, len(items))
+			for k, item := range items {
+				var err error
+				if values[k], err = defaultValue(s.ItemType(), item, typed); err != nil {
+					return nil, err
+				}
+			}
+			return values, nil
+		}
+	case *schema.UnionField:
+		// Defaults for unions are for the first type in the union
+		return defaultValue(s.AvroTypes()[0], v, typed)
+	case *schema.Reference:
+		return defaultDefinition(s.Def, v, typed)
+	}
+	return nil, fmt.Errorf("Invalid default %v for %v", v, t.Name())
+}
+
+func defaultDefinition(d schema.Definition, v interface{}, typed bool) (interface{}, error) {
+	switch def := d.(type) {
+	case *schema.EnumDefinition:
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+	case *schema.FixedDefinition:
+		if str, ok := v.(string); ok {
+			if b := jsonBytes(str); len(b) == def.SizeBytes() {
+				return b, nil
+			}
+		}
+	case *schema.RecordDefinition:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		record := NewRecord(def)
+		for _, field := range def.Fields() {
+			fieldValue, ok := fields[field.Name()]
+			if !ok {
+				if !field.HasDefault() {
+					return nil, fmt.Errorf("Invalid default for %v: no value for field %v", def.Name(), field.Name())
+				}
+				fieldValue = field.Default()
+			}
+			var err error
+			if record.Values[field.Index()], err = defaultValue(field.Type(), fieldValue, typed); err != nil {
+				return nil, err
+			}
+		}
+		if typed {
+			return record, nil
+		}
+		return record.fieldMap(), nil
+	}
+	return nil, fmt.Errorf("Invalid default %v for %v", v, d.Name())
+}
+
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jsonBytes decodes the default for a bytes or fixed field, where each character is a byte
+func jsonBytes(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		b = append(b, byte(r))
+	}
+	return b
+}
@@ -0,0 +1,385 @@
+package generic
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/clear-street/gogen-avro/schema"
+)
+
+// Encoder writes generic values in the Avro binary encoding for a schema.
+// Values are validated against the schema as they're encoded, missing record fields are filled
+// with their defaults, and the branch of a union is picked from the type of the value.
+// It accepts the values returned by a Decoder, as well as other integer and floating point types,
+// typed slices and maps with string keys.
+type Encoder struct {
+	schema     schema.AvroType
+	schemaJSON string
+}
+
+// NewEncoder returns an Encoder for the schema
+func NewEncoder(t schema.AvroType) (*Encoder, error) {
+	def, err := t.Definition(make(map[schema.QualifiedName]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	schemaJSON, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{schema: t, schemaJSON: string(schemaJSON)}, nil
+}
+
+// NewEncoderSchemaBytes parses the JSON schema and returns an Encoder for it
+func NewEncoderSchemaBytes(s []byte) (*Encoder, error) {
+	t, err := ParseSchema(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncoder(t)
+}
+
+// Schema returns the JSON schema values are encoded with
+func (e *Encoder) Schema() string {
+	return e.schemaJSON
+}
+
+// Encode validates v and writes it to w. Nothing is written if v isn't valid for the schema.
+func (e *Encoder) Encode(w io.Writer, v interface{}) error {
+	buf, err := e.AppendEncoded(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// AppendEncoded validates v and appends its encoding to buf
+func (e *Encoder) AppendEncoded(buf []byte, v interface{}) ([]byte, error) {
+	return appendValue(buf, e.schema, v, rootPath(e.schema))
+}
+
+// rootPath is the start of the path in errors, which is the name of the top-level record
+func rootPath(t schema.AvroType) string {
+	if ref, ok := t.(*schema.Reference); ok {
+		return ref.Def.AvroName().Name
+	}
+	return t.Name()
+}
+
+// Datum pairs a value with an Encoder, and implements container.AvroRecord,
+// so generic values can be written to a container.Writer created with the Encoder's Schema.
+func (e *Encoder) Datum(v interface{}) *Datum {
+	return &Datum{encoder: e, Value: v}
+}
+
+// Datum is a value with its schema, which implements container.AvroRecord
+type Datum struct {
+	encoder *Encoder
+	Value   interface{}
+}
+
+func (d *Datum) Serialize(w io.Writer) error {
+	return d.encoder.Encode(w, d.Value)
+}
+
+func (d *Datum) Schema() string {
+	return d.encoder.Schema()
+}
+
+// Serialize writes the record in the Avro binary encoding, so a *Record implements container.AvroRecord
+func (r *Record) Serialize(w io.Writer) error {
+	buf, err := appendRecord(nil, r.Def, r, r.Def.AvroName().Name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Schema returns the JSON schema of the record
+func (r *Record) Schema() string {
+	schemaJSON, err := r.Def.SchemaJSON()
+	if err != nil {
+		return ""
+	}
+	return string(schemaJSON)
+}
+
+// appendValue validates v against t and appends its encoding to b.
+// path is the location of the value in the datum, for error messages.
+func appendValue(b []byte, t schema.AvroType, v interface{}, path string) ([]byte, error) {
+	switch s := t.(type) {
+	case *schema.NullField:
+		if v == nil {
+			return b, nil
+		}
+	case *schema.BoolField:
+		if bv, ok := v.(bool); ok {
+			if bv {
+				return append(b, 1), nil
+			}
+			return append(b, 0), nil
+		}
+	case *schema.IntField:
+		if n, ok := toInt64(v); ok {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("%v: value %v out of range for int", path, n)
+			}
+			return encodeLong(b, n), nil
+		}
+	case *schema.LongField:
+		if n, ok := toInt64(v); ok {
+			return encodeLong(b, n), nil
+		}
+	case *schema.FloatField:
+		if f, ok := toFloat64(v); ok {
+			return encodeFloat(b, float32(f)), nil
+		}
+	case *schema.DoubleField:
+		if f, ok := toFloat64(v); ok {
+			return encodeDouble(b, f), nil
+		}
+	case *schema.StringField, *schema.BytesField:
+		switch str := v.(type) {
+		case string:
+			return append(encodeLong(b, int64(len(str))), str...), nil
+		case []byte:
+			return append(encodeLong(b, int64(len(str))), str...), nil
+		}
+	case *schema.ArrayField:
+		return appendArray(b, s, v, path)
+	case *schema.MapField:
+		return appendMap(b, s, v, path)
+	case *schema.UnionField:
+		return appendUnion(b, s, v, path)
+	case *schema.Reference:
+		return appendDefinition(b, s.Def, v, path)
+	default:
+		return nil, fmt.Errorf("%v: unsupported type %v", path, t.Name())
+	}
+	return nil, fmt.Errorf("%v: invalid value %#v for %v", path, v, t.Name())
+}
+
+func appendDefinition(b []byte, d schema.Definition, v interface{}, path string) ([]byte, error) {
+	switch def := d.(type) {
+	case *schema.RecordDefinition:
+		return appendRecord(b, def, v, path)
+	case *schema.EnumDefinition:
+		if symbol, ok := v.(string); ok {
+			for i, s := range def.Symbols() {
+				if s == symbol {
+					return encodeLong(b, int64(i)), nil
+				}
+			}
+			return nil, fmt.Errorf("%v: %q is not a symbol of enum %v", path, symbol, def.Name())
+		}
+	case *schema.FixedDefinition:
+		if fixed, ok := v.([]byte); ok {
+			if len(fixed) != def.SizeBytes() {
+				return nil, fmt.Errorf("%v: fixed %v has size %v, got %v bytes", path, def.Name(), def.SizeBytes(), len(fixed))
+			}
+			return append(b, fixed...), nil
+		}
+	}
+	return nil, fmt.Errorf("%v: invalid value %#v for %v", path, v, d.Name())
+}
+
+// appendRecord encodes a *Record or a map of field names to values.
+// Fields missing from the map are set to their default.
+func appendRecord(b []byte, def *schema.RecordDefinition, v interface{}, path string) ([]byte, error) {
+	var fields map[string]interface{}
+	switch r := v.(type) {
+	case *Record:
+		if r.Def.AvroName() != def.AvroName() {
+			return nil, fmt.Errorf("%v: expected record %v, got %v", path, def.AvroName(), r.Def.AvroName())
+		}
+		fields = r.fieldMap()
+	case map[string]interface{}:
+		for k := range r {
+			if def.FieldByName(k) == nil {
+				return nil, fmt.Errorf("%v: record %v has no field %q", path, def.Name(), k)
+			}
+		}
+		fields = r
+	default:
+		return nil, fmt.Errorf("%v: invalid value %#v for record %v", path, v, def.Name())
+	}
+
+	var err error
+	for _, field := range def.Fields() {
+		fieldPath := path + "." + field.Name()
+		value, ok := fields[field.Name()]
+		if !ok {
+			if !field.HasDefault() {
+				return nil, fmt.Errorf("%v: missing value for field with no default", fieldPath)
+			}
+			if value, err = defaultValue(field.Type(), field.Default(), false); err != nil {
+				return nil, fmt.Errorf("%v: %v", fieldPath, err)
+			}
+		}
+		if b, err = appendValue(b, field.Type(), value, fieldPath); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendArray encodes the items of a slice as a single block
+func appendArray(b []byte, array *schema.ArrayField, v interface{}, path string) ([]byte, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		value := reflect.ValueOf(v)
+		if v == nil || value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+			return nil, fmt.Errorf("%v: invalid value %#v for array", path, v)
+		}
+		items = make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).Interface()
+		}
+	}
+
+	var err error
+	if len(items) > 0 {
+		b = encodeLong(b, int64(len(items)))
+		for i, item := range items {
+			if b, err = appendValue(b, array.ItemType(), item, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return encodeLong(b, 0), nil
+}
+
+// appendMap encodes the entries of a map with string keys as a single block
+func appendMap(b []byte, m *schema.MapField, v interface{}, path string) ([]byte, error) {
+	items, ok := v.(map[string]interface{})
+	if !ok {
+		value := reflect.ValueOf(v)
+		if v == nil || value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%v: invalid value %#v for map", path, v)
+		}
+		items = make(map[string]interface{}, value.Len())
+		for _, k := range value.MapKeys() {
+			items[k.String()] = value.MapIndex(k).Interface()
+		}
+	}
+
+	// Keys are sorted so the same map is always encoded the same way
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var err error
+	if len(keys) > 0 {
+		b = encodeLong(b, int64(len(keys)))
+		for _, k := range keys {
+			b = append(encodeLong(b, int64(len(k))), k...)
+			if b, err = appendValue(b, m.ItemType(), items[k], fmt.Sprintf("%v[%q]", path, k)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return encodeLong(b, 0), nil
+}
+
+// appendUnion encodes v as the first branch of the union whose Go type matches it exactly,
+// or failing that the first branch it's valid for
+func appendUnion(b []byte, union *schema.UnionField, v interface{}, path string) ([]byte, error) {
+	branches := union.AvroTypes()
+	for i, t := range branches {
+		if isNativeType(t, v) {
+			return appendValue(encodeLong(b, int64(i)), t, v, path)
+		}
+	}
+	for i, t := range branches {
+		if encoded, err := appendValue(encodeLong(b, int64(i)), t, v, path); err == nil {
+			return encoded, nil
+		}
+	}
+	return nil, fmt.Errorf("%v: no branch of union %v for value %#v", path, union.Name(), v)
+}
+
+// isNativeType returns whether v has the Go type a Decoder uses for t
+func isNativeType(t schema.AvroType, v interface{}) bool {
+	switch s := t.(type) {
+	case *schema.NullField:
+		return v == nil
+	case *schema.BoolField:
+		_, ok := v.(bool)
+		return ok
+	case *schema.IntField:
+		_, ok := v.(int32)
+		return ok
+	case *schema.LongField:
+		_, ok := v.(int64)
+		return ok
+	case *schema.FloatField:
+		_, ok := v.(float32)
+		return ok
+	case *schema.DoubleField:
+		_, ok := v.(float64)
+		return ok
+	case *schema.StringField:
+		_, ok := v.(string)
+		return ok
+	case *schema.BytesField:
+		_, ok := v.([]byte)
+		return ok
+	case *schema.Reference:
+		if def, ok := s.Def.(*schema.RecordDefinition); ok {
+			r, ok := v.(*Record)
+			return ok && r.Def.AvroName() == def.AvroName()
+		}
+	}
+	return false
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := value.Uint(); n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func encodeLong(b []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(b, scratch[:binary.PutVarint(scratch[:], v)]...)
+}
+
+func encodeFloat(b []byte, f float32) []byte {
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(f))
+	return append(b, scratch[:]...)
+}
+
+func encodeDouble(b []byte, f float64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+	return append(b, scratch[:]...)
+}
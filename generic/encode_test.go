@@ -0,0 +1,148 @@
+package generic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/container"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	d, err := NewDecoderSchemaBytes([]byte(eventReaderSchema), nil)
+	assert.Nil(t, err)
+	e, err := NewEncoder(d.Schema())
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, e.Encode(buf, eventFixture))
+	v, err := d.Decode(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, eventFixture, v)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestEncodeMatchesWriter(t *testing.T) {
+	e, err := NewEncoderSchemaBytes([]byte(eventWriterSchema))
+	assert.Nil(t, err)
+
+	// Values don't have to be the types the Decoder returns
+	encoded, err := e.AppendEncoded(nil, map[string]interface{}{
+		"id":      5,
+		"kind":    "B",
+		"hash":    []byte("hi"),
+		"note":    "n",
+		"tags":    []map[string]interface{}{{"key": "k"}},
+		"scores":  map[string]float64{"s": 1.5},
+		"dropped": "zz",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, encodeEvent("n"), encoded)
+}
+
+func TestEncodeFillsDefaults(t *testing.T) {
+	e, err := NewEncoderSchemaBytes([]byte(eventReaderSchema))
+	assert.Nil(t, err)
+
+	fields := make(map[string]interface{})
+	for k, v := range eventFixture {
+		if k != "added" && k != "extra" {
+			fields[k] = v
+		}
+	}
+	withDefaults, err := e.AppendEncoded(nil, fields)
+	assert.Nil(t, err)
+	explicit, err := e.AppendEncoded(nil, eventFixture)
+	assert.Nil(t, err)
+	assert.Equal(t, explicit, withDefaults)
+}
+
+func TestEncodeUnionBranch(t *testing.T) {
+	e, err := NewEncoderSchemaBytes([]byte(`["null", "int", "long", "string"]`))
+	assert.Nil(t, err)
+
+	for _, c := range []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{nil, []byte{0}},
+		{int32(1), []byte{2, 2}},
+		{int64(1), []byte{4, 2}},
+		// Values which aren't the exact type of a branch go to the first branch they're valid for
+		{1, []byte{2, 2}},
+		{1 << 40, append([]byte{4}, encodeLong(nil, 1<<40)...)},
+		{"a", []byte{6, 2, 'a'}},
+	} {
+		encoded, err := e.AppendEncoded(nil, c.value)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, encoded, "Encoding %#v", c.value)
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+	e, err := NewEncoderSchemaBytes([]byte(eventWriterSchema))
+	assert.Nil(t, err)
+
+	for _, c := range []struct {
+		field string
+		value interface{}
+		err   string
+	}{
+		{"id", "5", `Event.id: invalid value "5" for Int`},
+		{"id", int64(1) << 40, "Event.id: value 1099511627776 out of range for int"},
+		{"kind", "C", `Event.kind: "C" is not a symbol of enum Kind`},
+		{"hash", []byte("toolong"), "Event.hash: fixed Hash has size 2, got 7 bytes"},
+		{"note", 1.5, "Event.note: no branch of union UnionNullString for value 1.5"},
+		{"tags", []interface{}{map[string]interface{}{"key": 1}}, "Event.tags[0].key: invalid value 1 for String"},
+		{"scores", map[string]interface{}{"s": "high"}, `Event.scores["s"]: invalid value "high" for Double`},
+		{"unknown", 1, `Event: record Event has no field "unknown"`},
+	} {
+		fields := map[string]interface{}{
+			"id": 5, "kind": "A", "hash": []byte("hi"), "note": nil,
+			"tags": []interface{}{}, "scores": map[string]interface{}{}, "dropped": "",
+		}
+		fields[c.field] = c.value
+		_, err := e.AppendEncoded(nil, fields)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, c.err, err.Error())
+		}
+	}
+
+	_, err = e.AppendEncoded(nil, map[string]interface{}{"id": 5})
+	assert.Equal(t, "Event.kind: missing value for field with no default", err.Error())
+}
+
+func TestEncodeContainer(t *testing.T) {
+	e, err := NewEncoderSchemaBytes([]byte(eventReaderSchema))
+	assert.Nil(t, err)
+	d, err := NewDecoderSchemaBytes([]byte(eventReaderSchema), nil)
+	assert.Nil(t, err)
+	record, _, err := d.DecodeRecordBytes(mustEncode(t, e, eventFixture))
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	w, err := container.NewWriter(buf, container.Deflate, 10, e.Schema())
+	assert.Nil(t, err)
+	assert.Nil(t, w.WriteRecord(e.Datum(eventFixture)))
+	assert.Nil(t, w.WriteRecord(record))
+	assert.Nil(t, w.Flush())
+
+	r, err := container.NewReader(buf)
+	assert.Nil(t, err)
+	fileDecoder, err := NewDecoderSchemaBytes(r.AvroContainerSchema(), []byte(eventReaderSchema))
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		v, err := fileDecoder.Decode(r)
+		assert.Nil(t, err)
+		assert.Equal(t, eventFixture, v)
+	}
+}
+
+func mustEncode(t *testing.T, e *Encoder, v interface{}) []byte {
+	encoded, err := e.AppendEncoded(nil, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
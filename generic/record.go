@@ -0,0 +1,93 @@
+// Package generic reads and writes Avro data using only a schema, without generated code.
+//
+// Values are represented with plain Go types:
+//
+//	null              nil
+//	boolean           bool
+//	int               int32
+//	long              int64
+//	float             float32
+//	double            float64
+//	bytes, fixed      []byte
+//	string, enum      string
+//	array             []interface{}
+//	map               map[string]interface{}
+//	union             the value of the branch
+//	record            map[string]interface{}, or a *Record (a GenericRecord)
+package generic
+
+import (
+	"github.com/clear-street/gogen-avro/schema"
+)
+
+// Record is a decoded Avro record which keeps a reference to its schema.
+// Values are in the order of the schema's fields.
+type Record struct {
+	Def    *schema.RecordDefinition
+	Values []interface{}
+}
+
+// NewRecord returns a Record for the schema with every field set to nil
+func NewRecord(def *schema.RecordDefinition) *Record {
+	return &Record{
+		Def:    def,
+		Values: make([]interface{}, len(def.Fields())),
+	}
+}
+
+// Get returns the value of the field with the given name, and whether the record has such a field
+func (r *Record) Get(name string) (interface{}, bool) {
+	field := r.Def.FieldByName(name)
+	if field == nil {
+		return nil, false
+	}
+	return r.Values[field.Index()], true
+}
+
+// Set sets the value of the field with the given name, and returns false if the record has no such field
+func (r *Record) Set(name string, v interface{}) bool {
+	field := r.Def.FieldByName(name)
+	if field == nil {
+		return false
+	}
+	r.Values[field.Index()] = v
+	return true
+}
+
+// Map returns the values of the record, and of any records nested in it, keyed by field name
+func (r *Record) Map() map[string]interface{} {
+	m := r.fieldMap()
+	for k, v := range m {
+		m[k] = toMap(v)
+	}
+	return m
+}
+
+// fieldMap returns the values of the record keyed by field name, without converting nested records
+func (r *Record) fieldMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(r.Values))
+	for _, field := range r.Def.Fields() {
+		m[field.Name()] = r.Values[field.Index()]
+	}
+	return m
+}
+
+func toMap(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *Record:
+		return t.Map()
+	case []interface{}:
+		items := make([]interface{}, len(t))
+		for i, item := range t {
+			items[i] = toMap(item)
+		}
+		return items
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			values[k] = toMap(item)
+		}
+		return values
+	}
+	return v
+}
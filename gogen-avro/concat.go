@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/clear-street/gogen-avro/container"
+)
+
+const concatUsage = `Usage: %s concat [flags] <output file> <input file>...
+
+Concatenates OCF files into a single file by copying their blocks, without decoding or re-encoding the records.
+Every input must use the same codec and a schema with the same canonical form. The output file is written with
+the schema, codec and metadata of the first input, and a new random sync marker.
+
+Where 'flags' are:
+`
+
+// concat implements the concat subcommand and returns the exit code
+func concat(name string, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("concat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, concatUsage, name)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 1
+	}
+
+	inputs := make([]*os.File, 0, flags.NArg()-1)
+	defer func() {
+		for _, f := range inputs {
+			f.Close()
+		}
+	}()
+	readers := make([]*container.Reader, 0, flags.NArg()-1)
+	for _, fileName := range flags.Args()[1:] {
+		f, err := os.Open(fileName)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error reading file %q - %v\n", fileName, err)
+			return 2
+		}
+		inputs = append(inputs, f)
+		reader, err := container.NewReader(f)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error reading OCF header of %q - %v\n", fileName, err)
+			return 3
+		}
+		readers = append(readers, reader)
+	}
+
+	first := readers[0]
+	opts := []container.WriterOption{container.RandomSync()}
+	for key, value := range first.Metadata() {
+		if !strings.HasPrefix(key, "avro.") {
+			opts = append(opts, container.Metadata(key, value))
+		}
+	}
+
+	// Creating the output truncates it, which would destroy an input before it's copied
+	outputName := flags.Arg(0)
+	if outputInfo, err := os.Stat(outputName); err == nil {
+		for i, f := range inputs {
+			if inputInfo, err := f.Stat(); err == nil && os.SameFile(outputInfo, inputInfo) {
+				fmt.Fprintf(stderr, "Output file %q is the same file as input %q\n", outputName, flags.Arg(i+1))
+				return 1
+			}
+		}
+	}
+	output, err := os.Create(outputName)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error creating file %q - %v\n", outputName, err)
+		return 2
+	}
+	defer output.Close()
+	writer, err := container.NewWriter(output, first.Codec(), 1, string(first.AvroContainerSchema()), opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error writing file %q - %v\n", outputName, err)
+		return 4
+	}
+
+	var records int64
+	for i, reader := range readers {
+		n, err := writer.CopyBlocks(reader)
+		records += n
+		if err != nil {
+			fmt.Fprintf(stderr, "Error copying blocks from %q - %v\n", flags.Arg(i+1), err)
+			return 4
+		}
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(stderr, "Error writing file %q - %v\n", outputName, err)
+		return 4
+	}
+	if err := output.Close(); err != nil {
+		fmt.Fprintf(stderr, "Error writing file %q - %v\n", outputName, err)
+		return 4
+	}
+	fmt.Fprintf(stdout, "Copied %v records from %v files to %v\n", records, len(readers), outputName)
+	return 0
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/container"
+	"github.com/clear-street/gogen-avro/generic"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeOCF returns an OCF file holding events with ids from first to first+count-1, in blocks of 2
+func writeOCF(t *testing.T, codec container.Codec, first, count int, opts ...container.WriterOption) []byte {
+	encoder, err := generic.NewEncoderSchemaBytes([]byte(disasmWriterSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writer, err := container.NewWriter(&buf, codec, 2, encoder.Schema(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := first; i < first+count; i++ {
+		assert.Nil(t, writer.WriteRecord(encoder.Datum(map[string]interface{}{"id": int32(i), "name": "event"})))
+	}
+	assert.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestConcat(t *testing.T) {
+	dir := writeTempFiles(t, map[string][]byte{
+		"first.avro":  writeOCF(t, container.Deflate, 0, 5, container.Metadata("lineage", []byte("first"))),
+		"second.avro": writeOCF(t, container.Deflate, 5, 3, container.RandomSync()),
+		"empty.avro":  writeOCF(t, container.Deflate, 8, 0),
+	})
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output.avro")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := concat("gogen-avro", []string{output, filepath.Join(dir, "first.avro"), filepath.Join(dir, "empty.avro"), filepath.Join(dir, "second.avro")}, stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Copied 8 records from 3 files")
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reader, err := container.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, container.Deflate, reader.Codec())
+	assert.Equal(t, []byte("first"), reader.Metadata()["lineage"])
+
+	decoder, err := generic.NewDecoderSchemaBytes(reader.AvroContainerSchema(), []byte(disasmWriterSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		record, err := decoder.DecodeRecord(reader)
+		if !assert.Nil(t, err) {
+			return
+		}
+		id, _ := record.Get("id")
+		assert.Equal(t, int32(i), id)
+	}
+	_, err = decoder.DecodeRecord(reader)
+	assert.NotNil(t, err)
+}
+
+func TestConcatMismatch(t *testing.T) {
+	dir := writeTempFiles(t, map[string][]byte{
+		"deflate.avro": writeOCF(t, container.Deflate, 0, 2),
+		"snappy.avro":  writeOCF(t, container.Snappy, 2, 2),
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := concat("gogen-avro", []string{filepath.Join(dir, "output.avro"), filepath.Join(dir, "deflate.avro"), filepath.Join(dir, "snappy.avro")}, stdout, stderr)
+	assert.Equal(t, 4, code)
+	assert.Contains(t, stderr.String(), "snappy.avro")
+	assert.Contains(t, stderr.String(), "compressed with")
+}
+
+func TestConcatOutputIsInput(t *testing.T) {
+	first := writeOCF(t, container.Deflate, 0, 2)
+	dir := writeTempFiles(t, map[string][]byte{
+		"first.avro":  first,
+		"second.avro": writeOCF(t, container.Deflate, 2, 2),
+	})
+	defer os.RemoveAll(dir)
+	// A link is the same file under another name
+	link := filepath.Join(dir, "link.avro")
+	if err := os.Link(filepath.Join(dir, "first.avro"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, output := range []string{filepath.Join(dir, "first.avro"), link} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := concat("gogen-avro", []string{output, filepath.Join(dir, "second.avro"), filepath.Join(dir, "first.avro")}, stdout, stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "same file as input")
+
+		// The input is left as it was
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "first.avro"))
+		assert.Nil(t, err)
+		assert.Equal(t, first, contents)
+	}
+}
+
+func TestConcatUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 1, concat("gogen-avro", []string{"output.avro"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "Usage")
+	_, err := ioutil.ReadFile("output.avro")
+	assert.True(t, os.IsNotExist(err))
+}
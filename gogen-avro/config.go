@@ -24,6 +24,7 @@ type config struct {
 	containers      bool
 	shortUnions     bool
 	namespacedNames string
+	writerSchemas   []string
 	targetDir       string
 	files           []string
 }
@@ -37,9 +38,10 @@ func parseCmdLine() config {
 	flag.BoolVar(&cfg.containers, "containers", defaultContainers, "Whether to generate container writer methods.")
 	flag.BoolVar(&cfg.shortUnions, "short-unions", defaultShortUnions, "Whether to use shorter names for Union types.")
 	flag.StringVar(&cfg.namespacedNames, "namespaced-names", defaultNamespacedNames, "Whether to generate namespaced names for types. Default is \"none\"; \"short\" uses the last part of the namespace (last word after a separator); \"full\" uses all namespace string.")
+	writerSchemas := flag.String("writer-schemas", "", "Comma-separated list of historical writer schema files (or globs). Programs to read each of them are compiled and embedded in the generated readers.")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <target directory> <schema files>\n\nWhere 'flags' are:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <target directory> <schema files>\n       %s disasm [flags] <writer schema> [<reader schema>]\n       %s concat <output file> <input file>...\n\nWhere 'flags' are:\n", os.Args[0], os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -57,15 +59,22 @@ func parseCmdLine() config {
 	}
 
 	cfg.targetDir = flag.Arg(0)
-	cfg.files = make([]string, 0)
+	cfg.files = expandGlobs(flag.Args()[1:])
+	if *writerSchemas != "" {
+		cfg.writerSchemas = expandGlobs(strings.Split(*writerSchemas, ","))
+	}
+	return cfg
+}
 
-	for _, glob := range flag.Args()[1:] {
+func expandGlobs(globs []string) []string {
+	expanded := make([]string, 0)
+	for _, glob := range globs {
 		files, err := filepath.Glob(glob)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error parsing input file as glob: %v", err)
 			os.Exit(1)
 		}
-		cfg.files = append(cfg.files, files...)
+		expanded = append(expanded, files...)
 	}
-	return cfg
+	return expanded
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+)
+
+const disasmUsage = `Usage: %s disasm [flags] <writer schema> [<reader schema>]
+
+Prints the program compiled to read data written with the writer schema into the reader schema
+(the writer schema if none is given), followed by its error table.
+
+Where 'flags' are:
+`
+
+// disasm implements the disasm subcommand and returns the exit code
+func disasm(name string, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	trace := flags.String("trace", "", "File containing a single binary-encoded datum, without OCF framing. The datum is run through the program, printing every instruction with the frame registers and the bytes consumed.")
+	optimize := flags.Bool("optimize", false, "Print and trace the program after running compiler.Optimize on it.")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, disasmUsage, name)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return 1
+	}
+
+	writer, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file %q - %v\n", flags.Arg(0), err)
+		return 2
+	}
+	reader := writer
+	if flags.NArg() == 2 {
+		if reader, err = ioutil.ReadFile(flags.Arg(1)); err != nil {
+			fmt.Fprintf(stderr, "Error reading file %q - %v\n", flags.Arg(1), err)
+			return 2
+		}
+	}
+
+	program, err := compiler.CompileSchemaBytes(writer, reader)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error compiling schemas - %v\n", err)
+		return 3
+	}
+	if *optimize {
+		if program, err = compiler.Optimize(program); err != nil {
+			fmt.Fprintf(stderr, "Error optimizing program - %v\n", err)
+			return 3
+		}
+	}
+	fmt.Fprint(stdout, program)
+
+	if *trace == "" {
+		return 0
+	}
+
+	datum, err := ioutil.ReadFile(*trace)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file %q - %v\n", *trace, err)
+		return 2
+	}
+	fmt.Fprintf(stdout, "\nTrace of %v:\n", *trace)
+	n, err := vm.EvalBytes(datum, program, types.Discard{}, vm.Trace(stdout))
+	if err != nil {
+		fmt.Fprintf(stdout, "Error after %v of %v bytes: %v\n", n, len(datum), err)
+		return 4
+	}
+	fmt.Fprintf(stdout, "Decoded %v of %v bytes\n", n, len(datum))
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const disasmWriterSchema = `{"type": "record", "name": "Event", "fields": [
+	{"name": "id", "type": "int"},
+	{"name": "name", "type": "string"}
+]}`
+
+const disasmReaderSchema = `{"type": "record", "name": "Event", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "priority", "type": "int", "default": 1}
+]}`
+
+func writeTempFiles(t *testing.T, files map[string][]byte) string {
+	dir, err := ioutil.TempDir("", "disasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDisasm(t *testing.T) {
+	dir := writeTempFiles(t, map[string][]byte{
+		"writer.avsc": []byte(disasmWriterSchema),
+		"reader.avsc": []byte(disasmReaderSchema),
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := disasm("gogen-avro", []string{filepath.Join(dir, "writer.avsc"), filepath.Join(dir, "reader.avsc")}, stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Event --> call(")
+	assert.Contains(t, stdout.String(), "set_def(1)")
+	assert.Contains(t, stdout.String(), "id --> enter(0)")
+	assert.Contains(t, stdout.String(), "skip(string)")
+}
+
+func TestDisasmOptimize(t *testing.T) {
+	dir := writeTempFiles(t, map[string][]byte{
+		"writer.avsc": []byte(disasmWriterSchema),
+		"reader.avsc": []byte(disasmReaderSchema),
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := disasm("gogen-avro", []string{"-optimize", filepath.Join(dir, "writer.avsc"), filepath.Join(dir, "reader.avsc")}, stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "int_to_long()")
+	assert.Contains(t, stdout.String(), "skip(string)")
+	assert.NotContains(t, stdout.String(), "skip_field")
+}
+
+func TestDisasmTrace(t *testing.T) {
+	// id = 3, name = "ab"
+	datum := []byte{6, 4, 'a', 'b'}
+	dir := writeTempFiles(t, map[string][]byte{
+		"writer.avsc": []byte(disasmWriterSchema),
+		"datum.bin":   datum,
+		"short.bin":   datum[:3],
+	})
+	defer os.RemoveAll(dir)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := disasm("gogen-avro", []string{"-trace", filepath.Join(dir, "datum.bin"), filepath.Join(dir, "writer.avsc")}, stdout, stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "String:ab")
+	assert.Contains(t, stdout.String(), "consumed: 4")
+	assert.Contains(t, stdout.String(), "Decoded 4 of 4 bytes")
+
+	stdout.Reset()
+	code = disasm("gogen-avro", []string{"-trace", filepath.Join(dir, "short.bin"), filepath.Join(dir, "writer.avsc")}, stdout, stderr)
+	assert.Equal(t, 4, code)
+	assert.Contains(t, stdout.String(), "Error after 2 of 3 bytes: Error decoding Event.name at offset 1")
+}
+
+func TestDisasmUsage(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 1, disasm("gogen-avro", []string{}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "disasm [flags] <writer schema> [<reader schema>]")
+}
@@ -17,6 +17,13 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "disasm" {
+		os.Exit(disasm(os.Args[0], os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "concat" {
+		os.Exit(concat(os.Args[0], os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	cfg := parseCmdLine()
 
 	namespace := schema.NewNamespace(cfg.shortUnions)
@@ -48,6 +55,13 @@ func main() {
 		}
 	}
 
+	if len(cfg.writerSchemas) > 0 {
+		if err := precompilePrograms(namespace, cfg.writerSchemas); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(5)
+		}
+	}
+
 	sortedDefs := make([]schema.QualifiedName, 0, len(namespace.Definitions))
 	for k, _ := range namespace.Definitions {
 		sortedDefs = append(sortedDefs, k)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/schema"
+)
+
+// precompilePrograms compiles a program for each historical writer schema against the record with
+// the same name in the namespace being generated, and embeds it in the generated code for that record.
+// Records that get any programs also get one for reading their own schema.
+func precompilePrograms(namespace *schema.Namespace, writerFiles []string) error {
+	readers := make(map[schema.QualifiedName]*schema.RecordDefinition)
+	for _, fileName := range writerFiles {
+		writerJson, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return fmt.Errorf("Error reading writer schema %q - %v", fileName, err)
+		}
+
+		writerNamespace := schema.NewNamespace(false)
+		writerType, err := writerNamespace.TypeForSchema(writerJson)
+		if err != nil {
+			return fmt.Errorf("Error decoding writer schema %q - %v", fileName, err)
+		}
+		if err = writerType.ResolveReferences(writerNamespace); err != nil {
+			return fmt.Errorf("Error decoding writer schema %q - %v", fileName, err)
+		}
+
+		writerRef, ok := writerType.(*schema.Reference)
+		if !ok {
+			return fmt.Errorf("Writer schema %q must be a record", fileName)
+		}
+		readerDef, ok := namespace.Definitions[writerRef.TypeName].(*schema.RecordDefinition)
+		if !ok {
+			return fmt.Errorf("Writer schema %q defines %v, which isn't a record in the reader schemas", fileName, writerRef.TypeName)
+		}
+
+		if err = addPrecompiledProgram(writerType, readerDef, writerJson); err != nil {
+			return fmt.Errorf("Error compiling writer schema %q - %v", fileName, err)
+		}
+		readers[readerDef.AvroName()] = readerDef
+	}
+
+	for name, readerDef := range readers {
+		readerJson, err := readerDef.SchemaJSON()
+		if err != nil {
+			return err
+		}
+		readerRef := &schema.Reference{TypeName: name, Def: readerDef}
+		if err = addPrecompiledProgram(readerRef, readerDef, readerJson); err != nil {
+			return fmt.Errorf("Error compiling schema for %v - %v", name, err)
+		}
+	}
+	return nil
+}
+
+func addPrecompiledProgram(writer schema.AvroType, readerDef *schema.RecordDefinition, writerJson []byte) error {
+	readerRef := &schema.Reference{TypeName: readerDef.AvroName(), Def: readerDef}
+	program, err := compiler.Compile(writer, readerRef)
+	if err != nil {
+		return err
+	}
+	if program, err = compiler.Optimize(program); err != nil {
+		return err
+	}
+
+	serialized, err := program.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	canonical, err := schema.Fingerprint(writerJson)
+	if err != nil {
+		return err
+	}
+	readerDef.AddPrecompiledProgram([]uint64{schema.FingerprintBytes(writerJson), canonical}, serialized)
+	return nil
+}
@@ -25,23 +25,23 @@ func %v(r %v, w io.Writer) error {
 const arrayWrapperTemplate = `
 type %[1]v %[2]v
 
-func (_ *%[1]v) DeserializeBoolean(v bool) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeInt(v int32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeLong(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeFloat(v float32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeBytes(v []byte) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeString(v string) { panic("Unsupported operation") }
-func (_ *%[1]v) SetUnionElem(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) Get(i int) types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) AppendMap(key string) types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) Finalize() { }
-func (_ *%[1]v) SetDefault(i int) { panic("Unsupported operation") }
-func (r *%[1]v) AppendArray() types.Field {
+func (_ *%[1]v) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeInt(v int32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeLong(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeFloat(v float32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeBytes(v []byte) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) SetUnionElem(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) Get(i int) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) AppendMap(key string) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) Finalize() error { return nil }
+func (_ *%[1]v) SetDefault(i int) error { return types.ErrUnsupportedOperation }
+func (r *%[1]v) AppendArray() (types.Field, error) {
 	var v %[3]v
         %[5]v
 	*r = append(*r, v)
-	return %[4]v
+	return %[4]v, nil
 }
 `
 
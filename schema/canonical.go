@@ -0,0 +1,246 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// The empty value of the CRC-64-AVRO Rabin fingerprint defined by the Avro spec
+const emptyFingerprint uint64 = 0xc15d213aa4d7a795
+
+var fingerprintTable = makeFingerprintTable()
+
+func makeFingerprintTable() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (emptyFingerprint & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}
+
+// FingerprintBytes returns the CRC-64-AVRO fingerprint of an arbitrary byte slice.
+func FingerprintBytes(b []byte) uint64 {
+	fp := emptyFingerprint
+	for _, v := range b {
+		fp = (fp >> 8) ^ fingerprintTable[byte(fp)^v]
+	}
+	return fp
+}
+
+// Fingerprint returns the CRC-64-AVRO fingerprint of the Parsing Canonical Form of a JSON schema.
+// Two schemas which only differ in whitespace, attribute order, docs or other non-essential attributes
+// have the same fingerprint.
+func Fingerprint(schemaJson []byte) (uint64, error) {
+	canonical, err := CanonicalForm(schemaJson)
+	if err != nil {
+		return 0, err
+	}
+	return FingerprintBytes(canonical), nil
+}
+
+// The attributes kept by the Parsing Canonical Form, in the order they're written
+var canonicalAttributes = []string{"name", "type", "fields", "symbols", "items", "values", "size"}
+
+var primitiveTypeNames = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// CanonicalForm returns the Parsing Canonical Form of a JSON schema, as defined by the Avro spec:
+// names are fully qualified, only attributes relevant to parsing are kept (in a fixed order),
+// primitives are written as bare strings and all whitespace is removed.
+func CanonicalForm(schemaJson []byte) ([]byte, error) {
+	var schema interface{}
+	if err := json.Unmarshal(schemaJson, &schema); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeCanonical(buf, "", schema, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, namespace string, schema interface{}, defined map[string]bool) error {
+	switch v := schema.(type) {
+	case string:
+		if primitiveTypeNames[v] {
+			return writeCanonicalString(buf, v)
+		}
+		return writeCanonicalString(buf, ParseAvroName(namespace, v).String())
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, t := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, namespace, t, defined); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case map[string]interface{}:
+		return writeCanonicalObject(buf, namespace, v, defined)
+	}
+	return NewWrongMapValueTypeError("type", "array, string, map", schema)
+}
+
+func writeCanonicalObject(buf *bytes.Buffer, namespace string, schema map[string]interface{}, defined map[string]bool) error {
+	typeStr, err := getMapString(schema, "type")
+	if err != nil {
+		// The type attribute may itself be a complex type definition, like {"type": {"type": "int"}}
+		if t, ok := schema["type"]; ok {
+			return writeCanonical(buf, namespace, t, defined)
+		}
+		return err
+	}
+
+	switch typeStr {
+	case "record", "error", "enum", "fixed":
+	default:
+		if primitiveTypeNames[typeStr] {
+			return writeCanonicalString(buf, typeStr)
+		}
+		if typeStr != "array" && typeStr != "map" {
+			return writeCanonical(buf, namespace, typeStr, defined)
+		}
+	}
+
+	if name, ok := schema["name"]; ok && typeStr != "array" && typeStr != "map" {
+		nameStr, ok := name.(string)
+		if !ok {
+			return NewWrongMapValueTypeError("name", "string", name)
+		}
+		if ns, ok := schema["namespace"]; ok {
+			if namespace, ok = ns.(string); !ok {
+				return NewWrongMapValueTypeError("namespace", "string", ns)
+			}
+		}
+		qualified := ParseAvroName(namespace, nameStr)
+		namespace = qualified.Namespace
+
+		// Named types are written out in full the first time they're defined, and by name afterwards
+		if defined[qualified.String()] {
+			return writeCanonicalString(buf, qualified.String())
+		}
+		defined[qualified.String()] = true
+		schema = copyWithName(schema, qualified.String())
+	}
+
+	if typeStr == "error" {
+		typeStr = "record"
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for _, attr := range canonicalAttributes {
+		val, ok := schema[attr]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeCanonicalString(buf, attr)
+		buf.WriteByte(':')
+
+		switch attr {
+		case "name":
+			err = writeCanonicalString(buf, val.(string))
+		case "type":
+			err = writeCanonicalString(buf, typeStr)
+		case "fields":
+			err = writeCanonicalFields(buf, namespace, val, defined)
+		case "symbols":
+			err = writeCanonicalJSON(buf, val)
+		case "items", "values":
+			err = writeCanonical(buf, namespace, val, defined)
+		case "size":
+			size, ok := val.(float64)
+			if !ok {
+				return NewWrongMapValueTypeError("size", "number", val)
+			}
+			_, err = fmt.Fprintf(buf, "%d", int64(size))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeCanonicalFields(buf *bytes.Buffer, namespace string, fields interface{}, defined map[string]bool) error {
+	fieldList, ok := fields.([]interface{})
+	if !ok {
+		return NewWrongMapValueTypeError("fields", "array", fields)
+	}
+
+	buf.WriteByte('[')
+	for i, f := range fieldList {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			return NewWrongMapValueTypeError("fields", "map[]", f)
+		}
+		name, err := getMapString(field, "name")
+		if err != nil {
+			return err
+		}
+		t, ok := field["type"]
+		if !ok {
+			return NewRequiredMapKeyError("type")
+		}
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"name":`)
+		writeCanonicalString(buf, name)
+		buf.WriteString(`,"type":`)
+		if err := writeCanonical(buf, namespace, t, defined); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func copyWithName(schema map[string]interface{}, name string) map[string]interface{} {
+	c := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		c[k] = v
+	}
+	c["name"] = name
+	return c
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) error {
+	return writeCanonicalJSON(buf, s)
+}
+
+// Write a JSON value without escaping HTML characters, so strings are written as UTF-8
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode always terminates the value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
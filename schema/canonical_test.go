@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Each of the transformations the spec defines for the Parsing Canonical Form
+var canonicalFormTests = []struct {
+	name, schema, canonical string
+}{
+	{"primitive string", `"int"`, `"int"`},
+	{"primitive object", `{"type": "long"}`, `"long"`},
+	{"nested primitive object", `{"type": {"type": "double"}}`, `"double"`},
+	{
+		"full names",
+		`{"type": "record", "name": "Rec", "namespace": "com.example", "fields": [{"name": "f", "type": "Rec"}]}`,
+		`{"name":"com.example.Rec","type":"record","fields":[{"name":"f","type":"com.example.Rec"}]}`,
+	},
+	{
+		"inherited namespace",
+		`{"type": "record", "name": "a.Outer", "fields": [{"name": "e", "type": {"type": "enum", "name": "E", "symbols": ["X"]}}]}`,
+		`{"name":"a.Outer","type":"record","fields":[{"name":"e","type":{"name":"a.E","type":"enum","symbols":["X"]}}]}`,
+	},
+	{
+		"strip",
+		`{"type": "fixed", "name": "F", "size": 4, "doc": "four bytes", "aliases": ["G"], "logicalType": "decimal", "precision": 4}`,
+		`{"name":"F","type":"fixed","size":4}`,
+	},
+	{
+		"strip field attributes",
+		`{"type": "record", "name": "R", "doc": "d", "fields": [{"name": "f", "type": "int", "default": 1, "doc": "x", "order": "descending", "aliases": ["g"]}]}`,
+		`{"name":"R","type":"record","fields":[{"name":"f","type":"int"}]}`,
+	},
+	{
+		"order",
+		`{"size": 2, "name": "F", "type": "fixed"}`,
+		`{"name":"F","type":"fixed","size":2}`,
+	},
+	{
+		"order array and map",
+		`[{"items": "string", "type": "array"}, {"values": "bytes", "type": "map"}]`,
+		`[{"type":"array","items":"string"},{"type":"map","values":"bytes"}]`,
+	},
+	{
+		"strings",
+		`{"type": "enum", "name": "E", "symbols": ["é", "<a>"]}`,
+		`{"name":"E","type":"enum","symbols":["é","<a>"]}`,
+	},
+	{"integers", `{"type": "fixed", "name": "F", "size": 1.6e1}`, `{"name":"F","type":"fixed","size":16}`},
+	{
+		"whitespace",
+		"{ \"type\" :\n\t\"array\" ,  \"items\" : [ \"null\" , \"int\" ] }",
+		`{"type":"array","items":["null","int"]}`,
+	},
+	{
+		"error is a record",
+		`{"type": "error", "name": "Oops", "fields": []}`,
+		`{"name":"Oops","type":"record","fields":[]}`,
+	},
+	{
+		"named type written once",
+		`{"type": "record", "name": "R", "fields": [{"name": "a", "type": {"type": "fixed", "name": "F", "size": 1}}, {"name": "b", "type": "F"}]}`,
+		`{"name":"R","type":"record","fields":[{"name":"a","type":{"name":"F","type":"fixed","size":1}},{"name":"b","type":"F"}]}`,
+	},
+}
+
+func TestCanonicalForm(t *testing.T) {
+	for _, c := range canonicalFormTests {
+		canonical, err := CanonicalForm([]byte(c.schema))
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.canonical, string(canonical), c.name)
+	}
+}
+
+func TestCanonicalFormErrors(t *testing.T) {
+	for _, schema := range []string{
+		`{`,
+		`1`,
+		`{"type": "fixed", "name": "F", "size": "1"}`,
+		`{"type": "record", "name": "R", "fields": {}}`,
+		`{"type": "record", "name": "R", "fields": [{"name": "f"}]}`,
+		`{"type": "record", "name": 1, "fields": []}`,
+	} {
+		_, err := CanonicalForm([]byte(schema))
+		assert.NotNil(t, err, schema)
+	}
+}
+
+// Fingerprints from the interoperability tests in the Avro project, as signed 64-bit integers
+var fingerprintTests = []struct {
+	schema      string
+	fingerprint int64
+}{
+	{`"null"`, 7195948357588979594},
+	{`"boolean"`, -6970731678124411036},
+	{`"int"`, 8247732601305521295},
+	{`"long"`, -3434872931120570953},
+	{`"float"`, 5583340709985441680},
+	{`"double"`, -8181574048448539266},
+	{`"bytes"`, 5746618253357095269},
+	{`"string"`, -8142146995180207161},
+	{`{"type": "string", "doc": "ignored"}`, -8142146995180207161},
+}
+
+func TestFingerprint(t *testing.T) {
+	for _, c := range fingerprintTests {
+		fingerprint, err := Fingerprint([]byte(c.schema))
+		assert.Nil(t, err, c.schema)
+		assert.Equal(t, c.fingerprint, int64(fingerprint), c.schema)
+	}
+}
+
+func TestFingerprintBytes(t *testing.T) {
+	// The fingerprint of no data is the empty value defined by the spec
+	assert.Equal(t, uint64(0xc15d213aa4d7a795), FingerprintBytes(nil))
+
+	// Schemas which only differ in non-essential attributes have the same fingerprint
+	a, err := Fingerprint([]byte(`{"type": "record", "name": "R", "namespace": "n", "fields": [{"name": "f", "type": "int"}]}`))
+	assert.Nil(t, err)
+	b, err := Fingerprint([]byte(`{"fields": [{"type": "int", "name": "f", "doc": "a field"}], "name": "n.R", "type": "record"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+
+	c, err := Fingerprint([]byte(`{"type": "record", "name": "R", "namespace": "n", "fields": [{"name": "f", "type": "long"}]}`))
+	assert.Nil(t, err)
+	assert.NotEqual(t, a, c)
+}
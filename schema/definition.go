@@ -45,3 +45,17 @@ func Contains(p *generator.Package, def HasAvroName) bool {
 	defPath := imprt.Path(p.Root(), def.AvroName().Namespace)
 	return pkgPath == defPath
 }
+
+// namesMatch returns whether a writer's named type can be resolved to a reader's by name:
+// either their full names are the same, or the writer's full name is one of the reader's aliases
+func namesMatch(writer, reader Definition) bool {
+	if writer.AvroName() == reader.AvroName() {
+		return true
+	}
+	for _, alias := range reader.Aliases() {
+		if alias == writer.AvroName() {
+			return true
+		}
+	}
+	return false
+}
@@ -84,6 +84,10 @@ func (e *EnumDefinition) Aliases() []QualifiedName {
 	return e.aliases
 }
 
+func (e *EnumDefinition) Symbols() []string {
+	return e.symbols
+}
+
 func (e *EnumDefinition) GoType() string {
 	return generator.ToPublicName(e.name.Name)
 }
@@ -209,7 +213,7 @@ func (s *EnumDefinition) DefaultValue(p *generator.Package, lvalue string, rvalu
 
 func (s *EnumDefinition) IsReadableBy(d Definition) bool {
 	otherEnum, ok := d.(*EnumDefinition)
-	return ok && otherEnum.name == s.name
+	return ok && namesMatch(s, otherEnum)
 }
 
 func (s *EnumDefinition) WrapperType() string {
@@ -16,21 +16,22 @@ func %v(r %v, w io.Writer) error {
 const fixedFieldTemplate = `
 type %[1]v %[2]v
 
-func (_ *%[1]v) DeserializeBoolean(v bool) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeInt(v int32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeLong(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeFloat(v float32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (r *%[1]v) DeserializeBytes(v []byte) { 
+func (_ *%[1]v) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeInt(v int32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeLong(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeFloat(v float32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (r *%[1]v) DeserializeBytes(v []byte) error {
 	copy((*r)[:], v)
+	return nil
 }
-func (_ *%[1]v) DeserializeString(v string) { panic("Unsupported operation") }
-func (_ *%[1]v) SetUnionElem(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) Get(i int) types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) AppendMap(key string) types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) AppendArray() types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) Finalize() { }
-func (_ *%[1]v) SetDefault(i int) { panic("Unsupported operation") }
+func (_ *%[1]v) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) SetUnionElem(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) Get(i int) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) AppendMap(key string) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) Finalize() error { return nil }
+func (_ *%[1]v) SetDefault(i int) error { return types.ErrUnsupportedOperation }
 `
 
 type FixedDefinition struct {
@@ -127,7 +128,7 @@ func (s *FixedDefinition) DefaultValue(p *generator.Package, lvalue string, rval
 
 func (s *FixedDefinition) IsReadableBy(d Definition) bool {
 	if fixed, ok := d.(*FixedDefinition); ok {
-		return fixed.sizeBytes == s.sizeBytes && fixed.name == s.name
+		return fixed.sizeBytes == s.sizeBytes && namesMatch(s, fixed)
 	}
 	return false
 }
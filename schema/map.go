@@ -42,33 +42,34 @@ func New%[1]v() *%[1]v {
 	}
 }
 
-func (_ *%[1]v) DeserializeBoolean(v bool) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeInt(v int32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeLong(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeFloat(v float32) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeBytes(v []byte) { panic("Unsupported operation") }
-func (_ *%[1]v) DeserializeString(v string) { panic("Unsupported operation") }
-func (_ *%[1]v) SetUnionElem(v int64) { panic("Unsupported operation") }
-func (_ *%[1]v) Get(i int) types.Field { panic("Unsupported operation") }
-func (_ *%[1]v) SetDefault(i int) { panic("Unsupported operation") }
-func (r *%[1]v) Finalize() { 
+func (_ *%[1]v) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeInt(v int32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeLong(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeFloat(v float32) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeBytes(v []byte) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) SetUnionElem(v int64) error { return types.ErrUnsupportedOperation }
+func (_ *%[1]v) Get(i int) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *%[1]v) SetDefault(i int) error { return types.ErrUnsupportedOperation }
+func (r *%[1]v) Finalize() error {
 	for i := range r.keys {
 		r.M[r.keys[i]] = r.values[i]
 	}
 	r.keys = nil
 	r.values = nil
+	return nil
 }
 
-func (r *%[1]v) AppendMap(key string) types.Field { 
+func (r *%[1]v) AppendMap(key string) (types.Field, error) {
 	r.keys = append(r.keys, key)
 	var v %[3]v
         %[5]v
 	r.values = append(r.values, v)
-	return %[4]v
+	return %[4]v, nil
 }
 
-func (_ *%[1]v) AppendArray() types.Field { panic("Unsupported operation") }
+func (_ *%[1]v) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
 `
 
 type MapField struct {
@@ -222,7 +222,7 @@ func (n *Namespace) decodeRecordDefinition(namespace string, schemaMap map[strin
 		decodedFields = append(decodedFields, fieldStruct)
 	}
 
-	aliases, err := parseAliases(schemaMap, namespace)
+	aliases, err := parseAliases(schemaMap, ParseAvroName(namespace, name).Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +264,7 @@ func (n *Namespace) decodeEnumDefinition(namespace string, schemaMap map[string]
 		return nil, fmt.Errorf("'symbols' must be an array of strings")
 	}
 
-	aliases, err := parseAliases(schemaMap, namespace)
+	aliases, err := parseAliases(schemaMap, ParseAvroName(namespace, name).Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -308,7 +308,7 @@ func (n *Namespace) decodeFixedDefinition(namespace string, schemaMap map[string
 		return nil, err
 	}
 
-	aliases, err := parseAliases(schemaMap, namespace)
+	aliases, err := parseAliases(schemaMap, ParseAvroName(namespace, name).Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -442,6 +442,7 @@ func (n *Namespace) getTypeByName(namespace string, typeStr string, definition i
 }
 
 // parseAliases parses out all the aliases from a definition map - returns an empty slice if no aliases exist.
+// Aliases which aren't fully qualified are relative to the namespace of the type they're declared on.
 // Returns an error if the aliases key exists but the value isn't a list of strings.
 func parseAliases(objectMap map[string]interface{}, namespace string) ([]QualifiedName, error) {
 	aliases, ok := objectMap["aliases"]
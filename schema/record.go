@@ -55,7 +55,7 @@ func %v(r io.Reader, schema string) (%v, error) {
 	if schema == "" {
 		schemaBytes = []byte(t.Schema())
 	}
-	deser, err := compiler.CompileSchemaBytes(schemaBytes, []byte(t.Schema()))
+	deser, err := %v(schemaBytes, []byte(t.Schema()))
         if err != nil {
 		return nil, err
 	}
@@ -66,9 +66,16 @@ func %v(r io.Reader, schema string) (%v, error) {
 `
 
 const recordWriterTemplate = `
-func %v(writer io.Writer, codec container.Codec, recordsPerBlock int64) (*container.Writer, error) {
+func %v(writer io.Writer, codec container.Codec, recordsPerBlock int64, opts ...container.WriterOption) (*container.Writer, error) {
 	str := &%v{}
-	return container.NewWriter(writer, codec, recordsPerBlock, str.Schema())
+	return container.NewWriter(writer, codec, recordsPerBlock, str.Schema(), opts...)
+}
+`
+
+const recordAppendWriterTemplate = `
+func %v(file io.ReadWriteSeeker, recordsPerBlock int64, opts ...container.WriterOption) (*container.Writer, error) {
+	str := &%v{}
+	return container.NewAppendWriter(file, recordsPerBlock, str.Schema(), opts...)
 }
 `
 
@@ -82,45 +89,79 @@ func %v(r io.Reader) (%v, error) {
 `
 
 const recordFieldTemplate = `
-func (_ %[1]v) DeserializeBoolean(v bool) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeInt(v int32) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeLong(v int64) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeFloat(v float32) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeBytes(v []byte) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeString(v string) { panic("Unsupported operation") }
-func (_ %[1]v) SetUnionElem(v int64) { panic("Unsupported operation") }
-func (r %[1]v) Get(i int) types.Field {
+func (_ %[1]v) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeInt(v int32) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeLong(v int64) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeFloat(v float32) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeBytes(v []byte) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) SetUnionElem(v int64) error { return types.ErrUnsupportedOperation }
+func (r %[1]v) Get(i int) (types.Field, error) {
 	switch (i) {
 		%[2]v
 	}
-	panic("Unknown field index")
+	return nil, types.ErrUnknownFieldIndex
 }
-func (r %[1]v) SetDefault(i int) {
+func (r %[1]v) SetDefault(i int) error {
 	switch (i) {
 		%[3]v
 	}
-	panic("Unknown field index")
+	return types.ErrUnknownFieldIndex
 }
-func (_ %[1]v) AppendMap(key string) types.Field { panic("Unsupported operation") }
-func (_ %[1]v) AppendArray() types.Field { panic("Unsupported operation") }
-func (_ %[1]v) Finalize() { }
+func (_ %[1]v) AppendMap(key string) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ %[1]v) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ %[1]v) Finalize() error { return nil }
 `
 
 const recordReaderTemplate = `
 type %[1]v struct {
-	r io.Reader
+	r *container.Reader
 	p *vm.Program
 }
 
-func New%[1]v(r io.Reader) (*%[1]v, error){
-	containerReader, err := container.NewReader(r)
+func New%[1]v(r io.Reader, opts ...container.ReaderOption) (*%[1]v, error){
+	containerReader, err := container.NewReader(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return new%[1]v(containerReader)
+}
+
+// New%[1]vForSplit reads the records in the blocks of an OCF file which belong to the byte range [start, end),
+// so workers can each read part of one file. See container.NewSplitReader for how blocks are assigned to ranges.
+func New%[1]vForSplit(r io.ReaderAt, start, end int64, opts ...container.ReaderOption) (*%[1]v, error){
+	containerReader, err := container.NewSplitReader(r, start, end, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return new%[1]v(containerReader)
+}
 
+// New%[1]vAt reads an OCF file starting from a position returned by Position. Only the block of the position
+// is read, and the records before it in the block are skipped without building a struct for each of them.
+func New%[1]vAt(r io.ReadSeeker, pos container.Position, opts ...container.ReaderOption) (*%[1]v, error){
+	containerReader, err := container.NewReaderAt(r, pos, opts...)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := new%[1]v(containerReader)
+	if err != nil {
+		return nil, err
+	}
+	skip := vm.NewEvaluator(reader.p)
+	for i := int64(0); i < pos.Record(); i++ {
+		if err := skip.Eval(containerReader, types.Discard{}); err != nil {
+			return nil, err
+		}
+		containerReader.RecordRead()
+	}
+	return reader, nil
+}
+
+func new%[1]v(containerReader *container.Reader) (*%[1]v, error){
 	t := %[3]v
-	deser, err := compiler.CompileSchemaBytes([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
+	deser, err := %[4]v([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
 	if err != nil {
 		return nil, err
 	}
@@ -134,16 +175,131 @@ func New%[1]v(r io.Reader) (*%[1]v, error){
 func (r *%[1]v) Read() (%[2]v, error) {
 	t := %[3]v
         err := vm.Eval(r.r, r.p, t)
+	if err == nil {
+		r.r.RecordRead()
+	}
 	return t, err
 }
+
+// Position returns the position of the next record to be read, which can be saved to resume reading
+// from it later with New%[1]vAt
+func (r *%[1]v) Position() container.Position {
+	return r.r.Position()
+}
+
+// Metadata returns a copy of the metadata in the file header, including the avro.schema and avro.codec entries
+func (r *%[1]v) Metadata() map[string][]byte {
+	return r.r.Metadata()
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (r *%[1]v) Codec() container.Codec {
+	return r.r.Codec()
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (r *%[1]v) SyncMarker() [16]byte {
+	return r.r.SyncMarker()
+}
+`
+
+const recordParallelReaderTemplate = `
+type %[1]v struct {
+	r       *container.ParallelReader
+	records []%[2]v
+}
+
+// New%[1]v reads an OCF file, decompressing and decoding its blocks on the given number of workers
+// (GOMAXPROCS if it's zero). Records are still returned in the order they were written.
+// The reader must be closed to stop the workers.
+func New%[1]v(r io.Reader, workers int, opts ...container.ReaderOption) (*%[1]v, error){
+	containerReader, err := container.NewReader(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t := %[3]v
+	deser, err := %[4]v([]byte(containerReader.AvroContainerSchema()), []byte(t.Schema()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Each worker reuses one evaluator for all of its blocks
+	newDecoder := func() container.BlockDecoder {
+		evaluator := vm.NewEvaluator(deser)
+		return func(records []byte, count int64) (interface{}, error) {
+			var block []%[2]v
+			for i := int64(0); i < count; i++ {
+				t := %[3]v
+				n, err := evaluator.EvalBytes(records, t)
+				if err != nil {
+					return nil, err
+				}
+				records = records[n:]
+				block = append(block, t)
+			}
+			if len(records) > 0 {
+				return nil, fmt.Errorf("Block has %%v bytes left after %%v records", len(records), count)
+			}
+			return block, nil
+		}
+	}
+	return &%[1]v{
+		r: container.NewParallelReader(containerReader, workers, newDecoder),
+	}, nil
+}
+
+// Read returns the next record in the file, or io.EOF once every record has been read
+func (r *%[1]v) Read() (%[2]v, error) {
+	for len(r.records) == 0 {
+		block, err := r.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		r.records = block.([]%[2]v)
+	}
+	t := r.records[0]
+	r.records = r.records[1:]
+	return t, nil
+}
+
+// Close stops the workers decoding blocks ahead of Read
+func (r *%[1]v) Close() error {
+	return r.r.Close()
+}
+
+// Metadata returns a copy of the metadata in the file header, including the avro.schema and avro.codec entries
+func (r *%[1]v) Metadata() map[string][]byte {
+	return r.r.Metadata()
+}
+
+// Codec returns the codec the blocks in the file are compressed with
+func (r *%[1]v) Codec() container.Codec {
+	return r.r.Codec()
+}
+
+// SyncMarker returns the sync marker written after every block in the file
+func (r *%[1]v) SyncMarker() [16]byte {
+	return r.r.SyncMarker()
+}
+`
+
+const recordPrecompiledTemplate = `
+// Programs compiled ahead of time to read historical writer schemas into %v
+var %v = compiler.Precompiled{
+	Reader: %#x,
+	Programs: map[uint64]string{
+%v},
+}
 `
 
 type RecordDefinition struct {
-	name     QualifiedName
-	aliases  []QualifiedName
-	fields   []*Field
-	doc      string
-	metadata map[string]interface{}
+	name        QualifiedName
+	aliases     []QualifiedName
+	fields      []*Field
+	doc         string
+	metadata    map[string]interface{}
+	precompiled map[uint64][]byte
 }
 
 func NewRecordDefinition(name QualifiedName, aliases []QualifiedName, fields []*Field, doc string, metadata map[string]interface{}) *RecordDefinition {
@@ -253,6 +409,14 @@ func (r *RecordDefinition) recordWriterMethodDef() string {
 	return fmt.Sprintf(recordWriterTemplate, r.recordWriterMethod(), r.Name())
 }
 
+func (r *RecordDefinition) recordAppendWriterMethod() string {
+	return fmt.Sprintf("New%vAppendWriter", r.Name())
+}
+
+func (r *RecordDefinition) recordAppendWriterMethodDef() string {
+	return fmt.Sprintf(recordAppendWriterTemplate, r.recordAppendWriterMethod(), r.Name())
+}
+
 func (r *RecordDefinition) publicSerializerMethodDef(p *generator.Package) string {
 	return fmt.Sprintf(recordStructPublicSerializerTemplate, r.GoType(), r.SerializerMethod(p))
 }
@@ -261,16 +425,66 @@ func (r *RecordDefinition) filename() string {
 	return generator.ToSnake(r.Name()) + ".go"
 }
 
-func (r *RecordDefinition) schemaMethodDef() (string, error) {
+// SchemaJSON returns the JSON schema for this record, as returned by the generated Schema() method
+func (r *RecordDefinition) SchemaJSON() ([]byte, error) {
 	def, err := r.Definition(make(map[QualifiedName]interface{}))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return json.Marshal(def)
+}
 
-	schemaJson, _ := json.Marshal(def)
+func (r *RecordDefinition) schemaMethodDef() (string, error) {
+	schemaJson, err := r.SchemaJSON()
+	if err != nil {
+		return "", err
+	}
 	return fmt.Sprintf(recordSchemaTemplate, r.GoType(), strconv.Quote(string(schemaJson))), nil
 }
 
+// AddPrecompiledProgram embeds a serialized VM program in the generated code for this record.
+// The generated readers use it instead of compiling the schemas at runtime when the writer schema
+// matches one of the given fingerprints.
+func (r *RecordDefinition) AddPrecompiledProgram(fingerprints []uint64, program []byte) {
+	if r.precompiled == nil {
+		r.precompiled = make(map[uint64][]byte)
+	}
+	for _, f := range fingerprints {
+		r.precompiled[f] = program
+	}
+}
+
+func (r *RecordDefinition) precompiledVarName() string {
+	return "precompiled" + r.Name()
+}
+
+func (r *RecordDefinition) precompiledDef() (string, error) {
+	schemaJson, err := r.SchemaJSON()
+	if err != nil {
+		return "", err
+	}
+
+	fingerprints := make([]uint64, 0, len(r.precompiled))
+	for f := range r.precompiled {
+		fingerprints = append(fingerprints, f)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool { return fingerprints[i] < fingerprints[j] })
+
+	entries := ""
+	for _, f := range fingerprints {
+		entries += fmt.Sprintf("%#x: %v,\n", f, strconv.Quote(string(r.precompiled[f])))
+	}
+	return fmt.Sprintf(recordPrecompiledTemplate, r.GoType(), r.precompiledVarName(), FingerprintBytes(schemaJson), entries), nil
+}
+
+// The function used by the generated code to build a program from the writer and reader schemas
+func (r *RecordDefinition) programLoader() string {
+	if len(r.precompiled) == 0 {
+		return "compiler.CompileOptimizedSchemaBytes"
+	}
+	return r.precompiledVarName() + ".Program"
+}
+
 func (r *RecordDefinition) qualifiedNameMethodDef() (string, error) {
 	avroName := r.AvroName()
 	return fmt.Sprintf(recordQualifiedName, r.GoType(), avroName.Namespace, avroName.Name), nil
@@ -281,7 +495,7 @@ func (r *RecordDefinition) publicDeserializerMethod() string {
 }
 
 func (r *RecordDefinition) publicDeserializerMethodDef(p *generator.Package) string {
-	return fmt.Sprintf(recordStructPublicDeserializerTemplate, r.publicDeserializerMethod(), r.GoType(), r.ConstructorMethod(p))
+	return fmt.Sprintf(recordStructPublicDeserializerTemplate, r.publicDeserializerMethod(), r.GoType(), r.ConstructorMethod(p), r.programLoader())
 }
 
 func (r *RecordDefinition) schemaNameMethodDef() (string, error) {
@@ -334,6 +548,9 @@ func (r *RecordDefinition) AddStruct(p *generator.Package, containers bool) erro
 		if containers {
 			p.AddImport(r.filename(), "github.com/clear-street/gogen-avro/container")
 			p.AddFunction(r.filename(), "", r.recordWriterMethod(), r.recordWriterMethodDef())
+			p.AddFunction(r.filename(), "", r.recordAppendWriterMethod(), r.recordAppendWriterMethodDef())
+			p.AddImport(r.filename(), "fmt")
+			p.AddFunction(r.filename(), r.GoType(), "parallelReader", r.recordParallelReaderDef(p))
 		}
 
 		p.AddImport(r.filename(), "github.com/clear-street/gogen-avro/vm/types")
@@ -343,6 +560,13 @@ func (r *RecordDefinition) AddStruct(p *generator.Package, containers bool) erro
 		p.AddFunction(r.filename(), r.GoType(), "recordReader", r.recordReaderDef(p))
 		p.AddFunction(r.filename(), r.GoType(), r.ConstructorMethod(p), constructorMethodDef)
 		p.AddFunction(r.filename(), r.GoType(), r.publicDeserializerMethod(), r.publicDeserializerMethodDef(p))
+		if len(r.precompiled) > 0 {
+			precompiledDef, err := r.precompiledDef()
+			if err != nil {
+				return err
+			}
+			p.AddFunction(r.filename(), "", r.precompiledVarName(), precompiledDef)
+		}
 		for _, f := range r.fields {
 			f.Type().AddStruct(p, containers)
 		}
@@ -393,7 +617,12 @@ func (r *RecordDefinition) Definition(scope map[QualifiedName]interface{}) (inte
 	}
 
 	r.metadata["fields"] = fields
-	r.metadata["namespace"] = r.AvroName().Namespace
+	// An empty namespace is left out, since some readers like goavro reject it
+	if r.AvroName().Namespace != "" {
+		r.metadata["namespace"] = r.AvroName().Namespace
+	} else {
+		delete(r.metadata, "namespace")
+	}
 	return r.metadata, nil
 }
 
@@ -424,7 +653,7 @@ func (r *RecordDefinition) defaultMethodDef(p *generator.Package) (string, error
 			if err != nil {
 				return "", err
 			}
-			defaults += def + "\nreturn\n"
+			defaults += def + "\nreturn nil\n"
 		}
 	}
 	return defaults, nil
@@ -438,9 +667,9 @@ func (r *RecordDefinition) getMethodDef(p *generator.Package) string {
 			getBody += fmt.Sprintf("r.%v = %v\n", f.GoName(), constructor.ConstructorMethod(p))
 		}
 		if f.Type().WrapperType() == "" {
-			getBody += fmt.Sprintf("return r.%v\n", f.GoName())
+			getBody += fmt.Sprintf("return r.%v, nil\n", f.GoName())
 		} else {
-			getBody += fmt.Sprintf("return (*%v)(&r.%v)\n", f.Type().WrapperType(), f.GoName())
+			getBody += fmt.Sprintf("return (*%v)(&r.%v), nil\n", f.Type().WrapperType(), f.GoName())
 		}
 	}
 	return getBody
@@ -485,7 +714,15 @@ func (r *RecordDefinition) recordReaderTypeName() string {
 }
 
 func (r *RecordDefinition) recordReaderDef(p *generator.Package) string {
-	return fmt.Sprintf(recordReaderTemplate, r.recordReaderTypeName(), r.GoType(), r.ConstructorMethod(p))
+	return fmt.Sprintf(recordReaderTemplate, r.recordReaderTypeName(), r.GoType(), r.ConstructorMethod(p), r.programLoader())
+}
+
+func (r *RecordDefinition) recordParallelReaderTypeName() string {
+	return r.Name() + "ParallelReader"
+}
+
+func (r *RecordDefinition) recordParallelReaderDef(p *generator.Package) string {
+	return fmt.Sprintf(recordParallelReaderTemplate, r.recordParallelReaderTypeName(), r.GoType(), r.ConstructorMethod(p), r.programLoader())
 }
 
 func (r *RecordDefinition) GetReaderField(writerField *Field) *Field {
@@ -533,7 +770,7 @@ func (r *RecordDefinition) Fields() []*Field {
 
 func (s *RecordDefinition) IsReadableBy(d Definition) bool {
 	reader, ok := d.(*RecordDefinition)
-	return ok && reader.name == s.name
+	return ok && namesMatch(s, reader)
 }
 
 func (s *RecordDefinition) WrapperType() string {
@@ -27,25 +27,26 @@ func %v %v {
 `
 
 const unionFieldTemplate = `
-func (_ %[1]v) DeserializeBoolean(v bool) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeInt(v int32) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeFloat(v float32) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeDouble(v float64) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeBytes(v []byte) { panic("Unsupported operation") }
-func (_ %[1]v) DeserializeString(v string) { panic("Unsupported operation") }
-func (r %[1]v) DeserializeLong(v int64) { 
+func (_ %[1]v) DeserializeBoolean(v bool) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeInt(v int32) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeFloat(v float32) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeBytes(v []byte) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) DeserializeString(v string) error { return types.ErrUnsupportedOperation }
+func (r %[1]v) DeserializeLong(v int64) error {
 	r.UnionType = (%[2]v)(v)
+	return nil
 }
-func (r %[1]v) Get(i int) types.Field {
+func (r %[1]v) Get(i int) (types.Field, error) {
 	switch (i) {
 		%[3]v
 	}
-	panic("Unknown field index")
+	return nil, types.ErrUnknownFieldIndex
 }
-func (_ %[1]v) SetDefault(i int) { panic("Unsupported operation") }
-func (_ %[1]v) AppendMap(key string) types.Field { panic("Unsupported operation") }
-func (_ %[1]v) AppendArray() types.Field { panic("Unsupported operation") }
-func (_ %[1]v) Finalize()  { }
+func (_ %[1]v) SetDefault(i int) error { return types.ErrUnsupportedOperation }
+func (_ %[1]v) AppendMap(key string) (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ %[1]v) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ %[1]v) Finalize() error { return nil }
 `
 
 type UnionField struct {
@@ -190,11 +191,10 @@ func (s *UnionField) FieldsMethodDef(p *generator.Package) string {
 			getBody += fmt.Sprintf("r.%v = %v\n", name, constructor.ConstructorMethod(p))
 		}
 		if f.WrapperType() == "" {
-			getBody += fmt.Sprintf("return r.%v", name)
+			getBody += fmt.Sprintf("return r.%v, nil\n", name)
 		} else {
-			getBody += fmt.Sprintf("return (*%v)(&r.%v)", f.WrapperType(), name)
+			getBody += fmt.Sprintf("return (*%v)(&r.%v), nil\n", f.WrapperType(), name)
 		}
-		getBody += "\nbreak\n"
 	}
 	return fmt.Sprintf(unionFieldTemplate, s.GoType(), s.unionEnumType(), getBody)
 }
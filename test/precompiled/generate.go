@@ -0,0 +1,5 @@
+package avro
+
+//go:generate $GOPATH/bin/gogen-avro -writer-schemas=event_v1.avsc . event.avsc
+//go:generate mkdir -p v1
+//go:generate $GOPATH/bin/gogen-avro v1 event_v1.avsc
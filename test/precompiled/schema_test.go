@@ -0,0 +1,89 @@
+package avro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/container"
+	"github.com/clear-street/gogen-avro/schema"
+	v1 "github.com/clear-street/gogen-avro/test/precompiled/v1"
+	"github.com/clear-street/gogen-avro/vm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecompiledProgramsEmbedded(t *testing.T) {
+	writerSchema, err := ioutil.ReadFile("event_v1.avsc")
+	assert.Nil(t, err)
+
+	canonical, err := schema.Fingerprint(writerSchema)
+	assert.Nil(t, err)
+	readerCanonical, err := schema.Fingerprint([]byte(NewEvent().Schema()))
+	assert.Nil(t, err)
+
+	for _, fingerprint := range []uint64{schema.FingerprintBytes(writerSchema), canonical, readerCanonical} {
+		serialized, ok := precompiledEvent.Programs[fingerprint]
+		assert.True(t, ok)
+
+		_, err := vm.LoadProgram([]byte(serialized))
+		assert.Nil(t, err)
+	}
+
+	// The embedded program must be the one the generated code would have compiled at runtime
+	compiled, err := compiler.CompileOptimizedSchemaBytes(writerSchema, []byte(NewEvent().Schema()))
+	assert.Nil(t, err)
+	loaded, err := vm.LoadProgram([]byte(precompiledEvent.Programs[canonical]))
+	assert.Nil(t, err)
+	assert.Equal(t, compiled, loaded)
+}
+
+func TestReadHistoricalWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := v1.NewEventWriter(&buf, container.Null, 10)
+	assert.Nil(t, err)
+
+	assert.Nil(t, writer.WriteRecord(&v1.Event{ID: 1, Name: "first", Source: "a"}))
+	assert.Nil(t, writer.WriteRecord(&v1.Event{ID: 2, Name: "second", Source: "b"}))
+	assert.Nil(t, writer.Flush())
+
+	reader, err := NewEventReader(&buf)
+	assert.Nil(t, err)
+
+	event, err := reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, &Event{ID: 1, Name: "first", Priority: 1}, event)
+
+	event, err = reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, &Event{ID: 2, Name: "second", Priority: 1}, event)
+}
+
+func TestPrecompiledFallback(t *testing.T) {
+	// A writer schema that wasn't known at generation time is compiled at runtime
+	writerSchema := `{"type":"record","name":"Event","fields":[{"name":"id","type":"long"},{"name":"name","type":"string"},{"name":"extra","type":"boolean"}]}`
+	program, err := precompiledEvent.Program([]byte(writerSchema), []byte(NewEvent().Schema()))
+	assert.Nil(t, err)
+
+	compiled, err := compiler.CompileOptimizedSchemaBytes([]byte(writerSchema), []byte(NewEvent().Schema()))
+	assert.Nil(t, err)
+	assert.Equal(t, compiled, program)
+}
+
+func TestPrecompiledDifferentReader(t *testing.T) {
+	// The embedded programs were compiled for the generated reader, so another reader schema is compiled at runtime
+	writerSchema, err := ioutil.ReadFile("event_v1.avsc")
+	assert.Nil(t, err)
+	readerSchema := `{"type":"record","name":"Event","fields":[{"name":"id","type":"long"}]}`
+	program, err := precompiledEvent.Program(writerSchema, []byte(readerSchema))
+	assert.Nil(t, err)
+
+	compiled, err := compiler.CompileOptimizedSchemaBytes(writerSchema, []byte(readerSchema))
+	assert.Nil(t, err)
+	assert.Equal(t, compiled, program)
+
+	embedded, err := precompiledEvent.Program(writerSchema, []byte(NewEvent().Schema()))
+	assert.Nil(t, err)
+	assert.NotEqual(t, compiled, embedded)
+}
@@ -2,10 +2,21 @@ package avro
 
 import (
 	"bytes"
+	"compress/flate"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/clear-street/gogen-avro/container"
+	"github.com/clear-street/gogen-avro/container/avro"
 	"github.com/linkedin/goavro"
 	"github.com/stretchr/testify/assert"
 )
@@ -59,7 +70,9 @@ func roundTripWithCodec(codec container.Codec, t *testing.T) {
 	assert.Nil(t, err)
 
 	reader, err := goavro.NewOCFReader(bytes.NewReader(buf.Bytes()))
-	assert.Nil(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	var i int
 	for reader.Scan() {
@@ -106,3 +119,1002 @@ func roundTripGogenWithCodec(codec container.Codec, t *testing.T) {
 		assert.Equal(t, record, &fixtures[i])
 	}
 }
+
+// Round-trip enough records to fill many blocks through the parallel reader
+func TestParallelNullEncoding(t *testing.T) {
+	roundTripParallelWithCodec(container.Null, t)
+}
+
+func TestParallelDeflateEncoding(t *testing.T) {
+	roundTripParallelWithCodec(container.Deflate, t)
+}
+
+func TestParallelSnappyEncoding(t *testing.T) {
+	roundTripParallelWithCodec(container.Snappy, t)
+}
+
+func writeParallelFixtures(codec container.Codec, t *testing.T) ([]PrimitiveTestRecord, []byte) {
+	fixtures := make([]PrimitiveTestRecord, 0)
+	err := json.Unmarshal([]byte(fixtureJson), &fixtures)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, codec, 3)
+	assert.Nil(t, err)
+
+	var records []PrimitiveTestRecord
+	for i := 0; i < 100; i++ {
+		for _, f := range fixtures {
+			f.IntField = int32(i)
+			records = append(records, f)
+			assert.Nil(t, containerWriter.WriteRecord(&f))
+		}
+	}
+	assert.Nil(t, containerWriter.Flush())
+	return records, buf.Bytes()
+}
+
+func roundTripParallelWithCodec(codec container.Codec, t *testing.T) {
+	records, buf := writeParallelFixtures(codec, t)
+
+	for _, workers := range []int{0, 1, 4} {
+		reader, err := NewPrimitiveTestRecordParallelReader(bytes.NewReader(buf), workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range records {
+			record, err := reader.Read()
+			if !assert.Nil(t, err) {
+				break
+			}
+			assert.Equal(t, &records[i], record)
+		}
+		_, err = reader.Read()
+		assert.Equal(t, io.EOF, err)
+		assert.Nil(t, reader.Close())
+	}
+}
+
+func TestParallelReaderDecoderPerWorker(t *testing.T) {
+	_, buf := writeParallelFixtures(container.Deflate, t)
+
+	containerReader, err := container.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock sync.Mutex
+	decoders, blocks := 0, 0
+	newDecoder := func() container.BlockDecoder {
+		lock.Lock()
+		decoders++
+		lock.Unlock()
+		return func(records []byte, count int64) (interface{}, error) {
+			return count, nil
+		}
+	}
+	reader := container.NewParallelReader(containerReader, 3, newDecoder)
+	defer reader.Close()
+	for {
+		if _, err = reader.Next(); err != nil {
+			break
+		}
+		blocks++
+	}
+	assert.Equal(t, io.EOF, err)
+
+	// The blocks are decoded by at most one decoder per worker, rather than one per block
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, decoders >= 1 && decoders <= 3, "%v decoders", decoders)
+	assert.True(t, blocks > 3, "%v blocks", blocks)
+}
+
+func TestParallelReaderClose(t *testing.T) {
+	_, buf := writeParallelFixtures(container.Deflate, t)
+
+	reader, err := NewPrimitiveTestRecordParallelReader(bytes.NewReader(buf), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Read()
+	assert.Nil(t, err)
+
+	assert.Nil(t, reader.Close())
+	assert.Nil(t, reader.Close())
+	// The records already decoded from the current block are still returned
+	for {
+		if _, err = reader.Read(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, container.ErrReaderClosed, err)
+}
+
+func TestParallelReaderCorruptBlock(t *testing.T) {
+	_, buf := writeParallelFixtures(container.Null, t)
+
+	// Truncating the file cuts off the last block
+	reader, err := NewPrimitiveTestRecordParallelReader(bytes.NewReader(buf[:len(buf)-20]), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	for {
+		if _, err = reader.Read(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func writeWithOptions(t *testing.T, opts ...container.WriterOption) []byte {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Deflate, 2, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{StringField: "hello"}))
+	assert.Nil(t, containerWriter.Close())
+	return buf.Bytes()
+}
+
+func TestWriterRandomSync(t *testing.T) {
+	first, err := avro.DeserializeAvroContainerHeader(bytes.NewReader(writeWithOptions(t, container.RandomSync())))
+	assert.Nil(t, err)
+	second, err := avro.DeserializeAvroContainerHeader(bytes.NewReader(writeWithOptions(t, container.RandomSync())))
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.Sync, second.Sync)
+
+	sync := avro.Sync{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	buf := writeWithOptions(t, container.SyncMarker(sync))
+	header, err := avro.DeserializeAvroContainerHeader(bytes.NewReader(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, sync, header.Sync)
+	// The file ends with the sync marker after the only block
+	assert.Equal(t, sync[:], buf[len(buf)-16:])
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf))
+	assert.Nil(t, err)
+	record, err := reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", record.StringField)
+}
+
+func TestWriterMetadata(t *testing.T) {
+	buf := writeWithOptions(t, container.Metadata("lineage", []byte("hourly-job")))
+	header, err := avro.DeserializeAvroContainerHeader(bytes.NewReader(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hourly-job"), header.Meta["lineage"])
+	assert.Equal(t, []byte("deflate"), header.Meta["avro.codec"])
+
+	_, err = NewPrimitiveTestRecordWriter(&bytes.Buffer{}, container.Null, 2, container.Metadata("avro.codec", []byte("null")))
+	assert.NotNil(t, err)
+}
+
+func TestWriterDeflateLevel(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Deflate, 10, container.DeflateLevel(flate.BestCompression))
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: int32(i)}))
+	}
+	assert.Nil(t, containerWriter.Close())
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		record, err := reader.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, int32(i), record.IntField)
+	}
+
+	_, err = NewPrimitiveTestRecordWriter(&bytes.Buffer{}, container.Deflate, 10, container.DeflateLevel(42))
+	assert.NotNil(t, err)
+}
+
+func TestWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 10)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{}))
+
+	// Closing writes the partial block
+	headerOnly := buf.Len()
+	assert.Nil(t, containerWriter.Close())
+	assert.True(t, buf.Len() > headerOnly)
+
+	assert.Equal(t, container.ErrWriterClosed, containerWriter.WriteRecord(&PrimitiveTestRecord{}))
+	assert.Equal(t, container.ErrWriterClosed, containerWriter.Flush())
+	assert.Nil(t, containerWriter.Close())
+}
+
+func TestReaderMetadata(t *testing.T) {
+	sync := avro.Sync{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	buf := writeWithOptions(t, container.SyncMarker(sync), container.Metadata("lineage", []byte("hourly-job")))
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, container.Deflate, reader.Codec())
+	assert.Equal(t, [16]byte(sync), reader.SyncMarker())
+	metadata := reader.Metadata()
+	assert.Equal(t, []byte("hourly-job"), metadata["lineage"])
+	assert.Equal(t, []byte("deflate"), metadata["avro.codec"])
+	assert.Equal(t, []byte(NewPrimitiveTestRecord().Schema()), metadata["avro.schema"])
+
+	// Changing the returned metadata doesn't change the reader's copy
+	delete(metadata, "lineage")
+	assert.Equal(t, []byte("hourly-job"), reader.Metadata()["lineage"])
+
+	parallelReader, err := NewPrimitiveTestRecordParallelReader(bytes.NewReader(buf), 1)
+	assert.Nil(t, err)
+	defer parallelReader.Close()
+	assert.Equal(t, container.Deflate, parallelReader.Codec())
+	assert.Equal(t, [16]byte(sync), parallelReader.SyncMarker())
+	assert.Equal(t, []byte("hourly-job"), parallelReader.Metadata()["lineage"])
+}
+
+// rewriteHeader replaces the header of an OCF file, keeping its blocks
+func rewriteHeader(t *testing.T, buf []byte, change func(*avro.AvroContainerHeader)) []byte {
+	r := bytes.NewReader(buf)
+	header, err := avro.DeserializeAvroContainerHeader(r)
+	assert.Nil(t, err)
+	blocks := buf[len(buf)-r.Len():]
+
+	change(header)
+	var out bytes.Buffer
+	assert.Nil(t, header.Serialize(&out))
+	return append(out.Bytes(), blocks...)
+}
+
+func TestReaderMagic(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.Close())
+
+	_, err = NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+
+	corrupt := rewriteHeader(t, buf.Bytes(), func(h *avro.AvroContainerHeader) {
+		h.Magic = avro.Magic{'o', 'b', 'j', 1}
+	})
+	_, err = NewPrimitiveTestRecordReader(bytes.NewReader(corrupt))
+	assert.NotNil(t, err)
+}
+
+func TestReaderDefaultCodec(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{StringField: "hello"}))
+	assert.Nil(t, containerWriter.Close())
+
+	noCodec := rewriteHeader(t, buf.Bytes(), func(h *avro.AvroContainerHeader) {
+		delete(h.Meta, "avro.codec")
+	})
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(noCodec))
+	assert.Nil(t, err)
+	assert.Equal(t, container.Null, reader.Codec())
+	record, err := reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", record.StringField)
+}
+
+// xorCodec is a stand-in for a real compression codec, registered by the tests
+const xorCodec container.Codec = "test-xor"
+
+func xorBlock(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	for i, b := range block {
+		out[i] = b ^ 0x5a
+	}
+	return out, nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	container.RegisterCodec(xorCodec, xorBlock, xorBlock)
+
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, xorCodec, 2)
+	assert.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{StringField: "hello", IntField: int32(i)}))
+	}
+	assert.Nil(t, containerWriter.Close())
+	// The records aren't stored in the clear
+	assert.False(t, bytes.Contains(buf.Bytes()[len(buf.Bytes())-40:], []byte("hello")))
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, xorCodec, reader.Codec())
+	for i := 0; i < 5; i++ {
+		record, err := reader.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, int32(i), record.IntField)
+		assert.Equal(t, "hello", record.StringField)
+	}
+}
+
+func TestUnknownCodec(t *testing.T) {
+	_, err := NewPrimitiveTestRecordWriter(&bytes.Buffer{}, container.Codec("unknown"), 2)
+	assert.NotNil(t, err)
+
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.Close())
+	unknown := rewriteHeader(t, buf.Bytes(), func(h *avro.AvroContainerHeader) {
+		h.Meta["avro.codec"] = []byte("unknown")
+	})
+	_, err = NewPrimitiveTestRecordReader(bytes.NewReader(unknown))
+	assert.NotNil(t, err)
+}
+
+func TestSnappyChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Snappy, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{StringField: "hello"}))
+	assert.Nil(t, containerWriter.Close())
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	_, err = reader.Read()
+	assert.Nil(t, err)
+
+	// The checksum is the 4 bytes before the sync marker at the end of the block
+	corrupt := append([]byte{}, buf.Bytes()...)
+	corrupt[len(corrupt)-17] ^= 0xff
+	reader, err = NewPrimitiveTestRecordReader(bytes.NewReader(corrupt))
+	assert.Nil(t, err)
+	_, err = reader.Read()
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "checksum")
+	}
+}
+
+func TestWriteBlock(t *testing.T) {
+	var source bytes.Buffer
+	sourceWriter, err := NewPrimitiveTestRecordWriter(&source, container.Snappy, 2)
+	assert.Nil(t, err)
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, sourceWriter.WriteRecord(&PrimitiveTestRecord{IntField: int32(i)}))
+	}
+	assert.Nil(t, sourceWriter.Close())
+
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Snappy, 10, container.RandomSync())
+	assert.Nil(t, err)
+	// The record in the Writer's buffer is written before the copied blocks
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: 0}))
+
+	sourceReader, err := container.NewReader(bytes.NewReader(source.Bytes()))
+	assert.Nil(t, err)
+	block, err := sourceReader.ReadBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), block.NumRecords)
+	assert.Nil(t, containerWriter.WriteBlock(block))
+	copied, err := containerWriter.CopyBlocks(sourceReader)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), copied)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: 4}))
+	assert.Nil(t, containerWriter.Close())
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	for i := 0; i <= 4; i++ {
+		record, err := reader.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, int32(i), record.IntField)
+	}
+}
+
+func TestCopyBlocksSchemaMismatch(t *testing.T) {
+	var source bytes.Buffer
+	sourceWriter, err := container.NewWriter(&source, container.Null, 2, `{"type": "record", "name": "Other", "fields": []}`)
+	assert.Nil(t, err)
+	assert.Nil(t, sourceWriter.Close())
+
+	containerWriter, err := NewPrimitiveTestRecordWriter(&bytes.Buffer{}, container.Null, 2)
+	assert.Nil(t, err)
+	sourceReader, err := container.NewReader(bytes.NewReader(source.Bytes()))
+	assert.Nil(t, err)
+	_, err = containerWriter.CopyBlocks(sourceReader)
+	assert.NotNil(t, err)
+}
+
+// writeTempOCF writes records with the given ids to a new temporary file, and returns its name
+func writeTempOCF(t *testing.T, ids ...int32) string {
+	f, err := ioutil.TempFile("", "append")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	containerWriter, err := NewPrimitiveTestRecordWriter(f, container.Deflate, 2, container.RandomSync())
+	assert.Nil(t, err)
+	for _, id := range ids {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: id}))
+	}
+	assert.Nil(t, containerWriter.Close())
+	return f.Name()
+}
+
+func appendRecords(t *testing.T, name string, ids ...int32) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	containerWriter, err := NewPrimitiveTestRecordAppendWriter(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: id}))
+	}
+	assert.Nil(t, containerWriter.Close())
+}
+
+func readIds(t *testing.T, name string) ([]int32, [16]byte) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reader, err := NewPrimitiveTestRecordParallelReader(f, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var ids []int32
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return ids, reader.SyncMarker()
+		}
+		if !assert.Nil(t, err) {
+			return ids, reader.SyncMarker()
+		}
+		ids = append(ids, record.IntField)
+	}
+}
+
+func TestAppendWriter(t *testing.T) {
+	name := writeTempOCF(t, 1, 2, 3)
+	defer os.Remove(name)
+	_, sync := readIds(t, name)
+
+	appendRecords(t, name, 4, 5)
+	appendRecords(t, name, 6)
+	ids, appendedSync := readIds(t, name)
+	assert.Equal(t, []int32{1, 2, 3, 4, 5, 6}, ids)
+	assert.Equal(t, sync, appendedSync)
+}
+
+func TestAppendWriterIncompleteBlock(t *testing.T) {
+	name := writeTempOCF(t, 1, 2, 3)
+	defer os.Remove(name)
+
+	// A crash while writing a block leaves part of it at the end of the file
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write([]byte{4, 40, 1, 2, 3})
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	appendRecords(t, name, 4)
+	ids, _ := readIds(t, name)
+	assert.Equal(t, []int32{1, 2, 3, 4}, ids)
+}
+
+func TestAppendWriterMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	other, err := container.NewWriter(&buf, container.Null, 2, `{"type": "record", "name": "Other", "fields": []}`)
+	assert.Nil(t, err)
+	assert.Nil(t, other.Close())
+
+	f, err := ioutil.TempFile("", "append")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(buf.Bytes())
+	assert.Nil(t, err)
+
+	_, err = NewPrimitiveTestRecordAppendWriter(f, 2)
+	assert.NotNil(t, err)
+
+	name := writeTempOCF(t, 1)
+	defer os.Remove(name)
+	existing, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer existing.Close()
+	_, err = NewPrimitiveTestRecordAppendWriter(existing, 2, container.Metadata("lineage", []byte("resumed")))
+	assert.NotNil(t, err)
+}
+
+func TestSplitReader(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Snappy, 3, container.RandomSync())
+	assert.Nil(t, err)
+	var expected []int32
+	for i := int32(0); i < 100; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: i, StringField: "split"}))
+		expected = append(expected, i)
+	}
+	assert.Nil(t, containerWriter.Close())
+	file := bytes.NewReader(buf.Bytes())
+	size := int64(buf.Len())
+
+	for _, splitSize := range []int64{1, 17, 100, size / 3, size} {
+		var ids []int32
+		for start := int64(0); start < size; start += splitSize {
+			reader, err := NewPrimitiveTestRecordReaderForSplit(file, start, start+splitSize)
+			if !assert.Nil(t, err) {
+				return
+			}
+			for {
+				record, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				ids = append(ids, record.IntField)
+			}
+		}
+		assert.Equal(t, expected, ids, "split size %v", splitSize)
+	}
+}
+
+func TestSplitReaderEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: 1}))
+	assert.Nil(t, containerWriter.Close())
+
+	// A range inside the header, and one past the last sync marker, have no blocks
+	for _, split := range [][2]int64{{0, 10}, {int64(buf.Len()) - 10, int64(buf.Len())}} {
+		reader, err := container.NewSplitReader(bytes.NewReader(buf.Bytes()), split[0], split[1])
+		assert.Nil(t, err)
+		_, err = reader.ReadBlock()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	_, err = container.NewSplitReader(bytes.NewReader(buf.Bytes()), 10, 5)
+	assert.NotNil(t, err)
+}
+
+// writeCorruptionFixture writes 10 blocks of 2 records, and returns the file and the offset of the end of each sync marker,
+// starting with the one in the header
+func writeCorruptionFixture(t *testing.T, codec container.Codec) ([]byte, []int) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, codec, 2, container.RandomSync())
+	assert.Nil(t, err)
+	for i := int32(0); i < 20; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: i, StringField: "corruption"}))
+	}
+	assert.Nil(t, containerWriter.Close())
+
+	reader, err := container.NewReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	sync := reader.SyncMarker()
+	var markers []int
+	for i := 0; ; {
+		j := bytes.Index(buf.Bytes()[i:], sync[:])
+		if j < 0 {
+			return buf.Bytes(), markers
+		}
+		i += j + len(sync)
+		markers = append(markers, i)
+	}
+}
+
+func readLeniently(t *testing.T, file []byte, parallel bool) ([]int32, []container.Corruption) {
+	var corruptions []container.Corruption
+	report := container.SkipCorruption(func(c container.Corruption) {
+		corruptions = append(corruptions, c)
+	})
+	read := func() (*PrimitiveTestRecord, error) { return nil, io.EOF }
+	if parallel {
+		reader, err := NewPrimitiveTestRecordParallelReader(bytes.NewReader(file), 2, report)
+		assert.Nil(t, err)
+		defer reader.Close()
+		read = reader.Read
+	} else {
+		reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(file), report)
+		assert.Nil(t, err)
+		read = reader.Read
+	}
+
+	var ids []int32
+	for {
+		record, err := read()
+		if errors.Is(err, io.EOF) {
+			return ids, corruptions
+		}
+		if !assert.Nil(t, err) {
+			return ids, corruptions
+		}
+		ids = append(ids, record.IntField)
+	}
+}
+
+func idsExcept(lost ...int32) []int32 {
+	var ids []int32
+	for i := int32(0); i < 20; i++ {
+		if len(lost) > 0 && lost[0] == i {
+			lost = lost[1:]
+			continue
+		}
+		ids = append(ids, i)
+	}
+	return ids
+}
+
+func TestSkipCorruptSyncMarker(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		file, markers := writeCorruptionFixture(t, container.Null)
+		// Damage the sync marker after the third block, so the third and fourth blocks are lost
+		file[markers[3]-1] ^= 0xff
+
+		ids, corruptions := readLeniently(t, file, parallel)
+		assert.Equal(t, idsExcept(4, 5, 6, 7), ids)
+		if assert.Equal(t, 1, len(corruptions)) {
+			assert.Equal(t, int64(markers[2]), corruptions[0].Start)
+			assert.Equal(t, int64(markers[4]), corruptions[0].End)
+			assert.Equal(t, int64(4), corruptions[0].Records)
+			assert.NotNil(t, corruptions[0].Err)
+		}
+	}
+}
+
+func TestSkipCorruptBlockSize(t *testing.T) {
+	file, markers := writeCorruptionFixture(t, container.Null)
+	// A negative size for the second block
+	file[markers[1]+1] = 1
+
+	ids, corruptions := readLeniently(t, file, false)
+	assert.Equal(t, idsExcept(2, 3), ids)
+	if assert.Equal(t, 1, len(corruptions)) {
+		assert.Equal(t, int64(markers[1]), corruptions[0].Start)
+		assert.Equal(t, int64(markers[2]), corruptions[0].End)
+	}
+
+	_, err := NewPrimitiveTestRecordReader(bytes.NewReader(file))
+	assert.Nil(t, err)
+}
+
+func TestSkipCorruptBlockSizeOverflow(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		file, markers := writeCorruptionFixture(t, container.Null)
+		// A size for the second block which overflows once the sync marker is added to it
+		_, n := binary.Varint(file[markers[1]+1:])
+		size := make([]byte, binary.MaxVarintLen64)
+		size = size[:binary.PutVarint(size, math.MaxInt64-10)]
+		damaged := append(append(append([]byte{}, file[:markers[1]+1]...), size...), file[markers[1]+1+n:]...)
+		shift := len(size) - n
+
+		ids, corruptions := readLeniently(t, damaged, parallel)
+		assert.Equal(t, idsExcept(2, 3), ids)
+		if assert.Equal(t, 1, len(corruptions)) {
+			assert.Equal(t, int64(markers[1]), corruptions[0].Start)
+			assert.Equal(t, int64(markers[2]+shift), corruptions[0].End)
+		}
+	}
+}
+
+func TestReadBlockDamagedSize(t *testing.T) {
+	file, markers := writeCorruptionFixture(t, container.Null)
+	_, n := binary.Varint(file[markers[1]+1:])
+	for _, length := range []int64{1 << 60, math.MaxInt64 - 10, -5} {
+		// Without SkipCorruption a damaged size is an error, and doesn't allocate the size it claims
+		size := make([]byte, binary.MaxVarintLen64)
+		size = size[:binary.PutVarint(size, length)]
+		damaged := append(append(append([]byte{}, file[:markers[1]+1]...), size...), file[markers[1]+1+n:]...)
+
+		reader, err := container.NewReader(bytes.NewReader(damaged))
+		assert.Nil(t, err)
+		_, err = reader.ReadBlock()
+		assert.Nil(t, err)
+		_, err = reader.ReadBlock()
+		assert.NotNil(t, err, "size %v", length)
+	}
+}
+
+func TestSkipTruncatedBlock(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		file, markers := writeCorruptionFixture(t, container.Deflate)
+		file = file[:markers[10]-5]
+
+		ids, corruptions := readLeniently(t, file, parallel)
+		assert.Equal(t, idsExcept(18, 19), ids)
+		if assert.Equal(t, 1, len(corruptions)) {
+			assert.Equal(t, int64(markers[9]), corruptions[0].Start)
+			assert.Equal(t, int64(len(file)), corruptions[0].End)
+			assert.Equal(t, io.ErrUnexpectedEOF, corruptions[0].Err)
+		}
+	}
+}
+
+func TestSkipChecksumFailure(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		file, markers := writeCorruptionFixture(t, container.Snappy)
+		// Damage the checksum at the end of the fifth block
+		file[markers[5]-17] ^= 0xff
+
+		ids, corruptions := readLeniently(t, file, parallel)
+		assert.Equal(t, idsExcept(8, 9), ids)
+		if assert.Equal(t, 1, len(corruptions)) {
+			assert.Equal(t, int64(markers[4]), corruptions[0].Start)
+			assert.Equal(t, int64(markers[5]), corruptions[0].End)
+			assert.Equal(t, int64(2), corruptions[0].Records)
+		}
+
+		// Without the option the whole read fails
+		reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(file))
+		assert.Nil(t, err)
+		for i := 0; i < 8; i++ {
+			_, err = reader.Read()
+			assert.Nil(t, err)
+		}
+		_, err = reader.Read()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestSkipCorruptionSplit(t *testing.T) {
+	file, markers := writeCorruptionFixture(t, container.Null)
+	// Damage the sync marker after the fifth block
+	file[markers[5]-1] ^= 0xff
+
+	// Split the file inside the fifth block, inside the damaged marker and inside the sixth block. The damage is
+	// in the gap between the end of the first split and the first good marker after it, where the second split starts.
+	for _, end := range []int64{int64(markers[4] + 10), int64(markers[5] - 8), int64(markers[5] + 5)} {
+		var ids []int32
+		var corruptions []container.Corruption
+		report := container.SkipCorruption(func(c container.Corruption) {
+			corruptions = append(corruptions, c)
+		})
+		for _, split := range [][2]int64{{0, end}, {end, int64(len(file))}} {
+			reader, err := NewPrimitiveTestRecordReaderForSplit(bytes.NewReader(file), split[0], split[1], report)
+			if !assert.Nil(t, err) {
+				return
+			}
+			for {
+				record, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				ids = append(ids, record.IntField)
+			}
+		}
+
+		// The first split reports everything up to the marker the second split starts after
+		assert.Equal(t, idsExcept(8, 9, 10, 11), ids, "split at %v", end)
+		if assert.Equal(t, 1, len(corruptions), "split at %v", end) {
+			assert.Equal(t, int64(markers[4]), corruptions[0].Start)
+			assert.Equal(t, int64(markers[6]), corruptions[0].End)
+		}
+	}
+}
+
+func TestParallelReaderCorruptionOrder(t *testing.T) {
+	file, markers := writeCorruptionFixture(t, container.Snappy)
+	// Damage the checksum of the second block, which is found by a worker,
+	// and the sync marker after the fifth, which is found reading ahead
+	file[markers[2]-17] ^= 0xff
+	file[markers[5]-1] ^= 0xff
+
+	// Both are reported from Next, in the order they appear in the file
+	ids, corruptions := readLeniently(t, file, true)
+	assert.Equal(t, idsExcept(2, 3, 8, 9, 10, 11), ids)
+	if assert.Equal(t, 2, len(corruptions)) {
+		assert.Equal(t, int64(markers[1]), corruptions[0].Start)
+		assert.Equal(t, int64(markers[2]), corruptions[0].End)
+		assert.Equal(t, int64(markers[4]), corruptions[1].Start)
+		assert.Equal(t, int64(markers[6]), corruptions[1].End)
+	}
+}
+
+func TestWriterBlockSize(t *testing.T) {
+	var buf bytes.Buffer
+	var blocks []container.BlockInfo
+	onBlock := container.OnBlock(func(b container.BlockInfo) {
+		blocks = append(blocks, b)
+	})
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 0, container.BlockSize(1000), onBlock)
+	assert.Nil(t, err)
+	record := &PrimitiveTestRecord{StringField: string(make([]byte, 300))}
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(record))
+	}
+	assert.Nil(t, containerWriter.Close())
+
+	// Each block is flushed once it holds 1000 bytes, which takes 4 records
+	var records []int64
+	for _, b := range blocks {
+		records = append(records, b.Records)
+	}
+	assert.Equal(t, []int64{4, 4, 2}, records)
+
+	// The offsets and sizes match the blocks in the file
+	reader, err := container.NewReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	sync := reader.SyncMarker()
+	offset := int64(bytes.Index(buf.Bytes(), sync[:]) + len(sync))
+	for _, b := range blocks {
+		assert.Equal(t, offset, b.Offset)
+		offset += b.Size
+		assert.Equal(t, sync[:], buf.Bytes()[offset-16:offset])
+	}
+	assert.Equal(t, int64(buf.Len()), offset)
+
+	_, err = NewPrimitiveTestRecordWriter(&buf, container.Null, 0, container.BlockSize(-1))
+	assert.NotNil(t, err)
+}
+
+func TestWriterBlockSizeAndCount(t *testing.T) {
+	var buf bytes.Buffer
+	var records []int64
+	onBlock := container.OnBlock(func(b container.BlockInfo) {
+		records = append(records, b.Records)
+	})
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Deflate, 3, container.BlockSize(1000), onBlock)
+	assert.Nil(t, err)
+	for _, size := range []int{10, 10, 10, 600, 600, 10} {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{StringField: string(make([]byte, size))}))
+	}
+	assert.Nil(t, containerWriter.Close())
+	assert.Equal(t, []int64{3, 2, 1}, records)
+}
+
+func TestFlushBlockInfo(t *testing.T) {
+	var buf bytes.Buffer
+	var blocks []container.BlockInfo
+	onBlock := container.OnBlock(func(b container.BlockInfo) {
+		blocks = append(blocks, b)
+	})
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Snappy, 10, onBlock)
+	assert.Nil(t, err)
+
+	// Nothing is written without any records
+	info, err := containerWriter.FlushBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, container.BlockInfo{}, info)
+
+	headerSize := int64(buf.Len())
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: int32(i)}))
+	}
+	info, err = containerWriter.FlushBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, container.BlockInfo{Offset: headerSize, Size: int64(buf.Len()) - headerSize, Records: 3}, info)
+	assert.Equal(t, []container.BlockInfo{info}, blocks)
+}
+
+func TestAppendWriterBlockOffsets(t *testing.T) {
+	name := writeTempOCF(t, 1, 2, 3)
+	defer os.Remove(name)
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var blocks []container.BlockInfo
+	containerWriter, err := NewPrimitiveTestRecordAppendWriter(f, 2, container.OnBlock(func(b container.BlockInfo) {
+		blocks = append(blocks, b)
+	}))
+	assert.Nil(t, err)
+	assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: 4}))
+	assert.Nil(t, containerWriter.Close())
+	if assert.Equal(t, 1, len(blocks)) {
+		assert.Equal(t, info.Size(), blocks[0].Offset)
+	}
+}
+
+func TestReaderPosition(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Deflate, 3)
+	assert.Nil(t, err)
+	for i := int32(0); i < 10; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: i}))
+	}
+	assert.Nil(t, containerWriter.Close())
+
+	// Checkpoint the position before every record, and after the last one
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	var checkpoints [][]byte
+	for {
+		checkpoint, err := reader.Position().MarshalText()
+		assert.Nil(t, err)
+		checkpoints = append(checkpoints, checkpoint)
+		if _, err := reader.Read(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, 11, len(checkpoints))
+
+	for i, checkpoint := range checkpoints {
+		var pos container.Position
+		assert.Nil(t, pos.UnmarshalText(checkpoint))
+		if i < 10 {
+			assert.Equal(t, int64(i%3), pos.Record())
+		}
+
+		resumed, err := NewPrimitiveTestRecordReaderAt(bytes.NewReader(buf.Bytes()), pos)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		var ids []int32
+		for {
+			record, err := resumed.Read()
+			if err != nil {
+				assert.True(t, errors.Is(err, io.EOF))
+				break
+			}
+			ids = append(ids, record.IntField)
+		}
+		assert.Equal(t, expectedIdsFrom(int32(i), 10), ids, "checkpoint %s", checkpoint)
+	}
+}
+
+func expectedIdsFrom(start, end int32) []int32 {
+	var ids []int32
+	for i := start; i < end; i++ {
+		ids = append(ids, i)
+	}
+	return ids
+}
+
+func TestReaderInvalidPosition(t *testing.T) {
+	var buf bytes.Buffer
+	containerWriter, err := NewPrimitiveTestRecordWriter(&buf, container.Null, 3)
+	assert.Nil(t, err)
+	for i := int32(0); i < 3; i++ {
+		assert.Nil(t, containerWriter.WriteRecord(&PrimitiveTestRecord{IntField: i}))
+	}
+	assert.Nil(t, containerWriter.Close())
+
+	reader, err := NewPrimitiveTestRecordReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	var pos container.Position
+	start, err := reader.Position().MarshalText()
+	assert.Nil(t, err)
+
+	// Past the end of the block
+	assert.Nil(t, pos.UnmarshalText([]byte(strings.Replace(string(start), ":0", ":3", 1))))
+	_, err = NewPrimitiveTestRecordReaderAt(bytes.NewReader(buf.Bytes()), pos)
+	assert.NotNil(t, err)
+
+	// Inside the header
+	assert.Nil(t, pos.UnmarshalText([]byte("4:0")))
+	_, err = NewPrimitiveTestRecordReaderAt(bytes.NewReader(buf.Bytes()), pos)
+	assert.NotNil(t, err)
+
+	// Not a block offset
+	assert.Nil(t, pos.UnmarshalText([]byte(fmt.Sprintf("%v:1", buf.Len()-20))))
+	_, err = NewPrimitiveTestRecordReaderAt(bytes.NewReader(buf.Bytes()), pos)
+	assert.NotNil(t, err)
+
+	for _, text := range []string{"", "1", "1:2:3", "a:1", "-1:0", "1:-1"} {
+		assert.NotNil(t, pos.UnmarshalText([]byte(text)), text)
+	}
+}
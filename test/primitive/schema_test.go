@@ -71,7 +71,7 @@ func TestRoundTrip(t *testing.T) {
 		err = f.Serialize(&buf)
 		assert.Nil(t, err)
 
-		target, err := DeserializePrimitiveTestRecord(&buf)
+		target, err := DeserializePrimitiveTestRecord(&buf, "")
 		assert.Nil(t, err)
 
 		assert.Equal(t, target, &f)
@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DecodeError is returned by Eval and EvalBytes when a datum can't be decoded.
+// Use errors.As to get at it, and Unwrap (or errors.Is/As again) for the underlying cause.
+type DecodeError struct {
+	// The path of the field being decoded, like `Order.items[3].price`.
+	// Record fields are separated by dots, array indexes and map keys are in brackets.
+	Path string
+	// The offset in the input of the start of the last value read
+	Offset int64
+	// The address of the instruction which failed, and the instruction itself, which is left zero if a bad jump
+	// in an unverified program took the pc out of range
+	PC          int
+	Instruction Instruction
+	// The underlying cause - an error from the input, a *LimitError, or a failure setting a field
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("Error decoding %v at offset %v (pc %v: %v): %v", path, e.Offset, e.PC, e.Instruction, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newError wraps an error from the current instruction in a DecodeError.
+// The path is filled in by withPath as the error is returned up the stack.
+func (e *evaluator) newError(err error) error {
+	return &DecodeError{
+		Offset:      e.r.valueOffset(),
+		PC:          e.pc,
+		Instruction: e.program.Instructions[e.pc],
+		Err:         err,
+	}
+}
+
+// withPath adds a path component to a DecodeError as it's returned up the stack.
+// Components are field or record names, or an index or key in brackets.
+func withPath(err error, component string) error {
+	decodeErr, ok := err.(*DecodeError)
+	if !ok || component == "" {
+		return err
+	}
+	if decodeErr.Path != "" && !strings.HasPrefix(decodeErr.Path, "[") {
+		component += "."
+	}
+	decodeErr.Path = component + decodeErr.Path
+	if limitErr, ok := decodeErr.Err.(*LimitError); ok {
+		limitErr.Path = decodeErr.Path
+	}
+	return decodeErr
+}
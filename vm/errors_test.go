@@ -0,0 +1,92 @@
+package vm_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeErrorTruncated(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+	// Cut the input off in the middle of the string field, which starts after the int, long, float and double
+	stringOffset := bytes.Index(buf, []byte("hello")) - 1
+	buf = buf[:stringOffset+3]
+
+	err := evalBoth(t, program, buf, newTestRecord)
+	var decodeErr *vm.DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, "TestRecord.stringField", decodeErr.Path)
+		assert.Equal(t, int64(stringOffset), decodeErr.Offset)
+		assert.Equal(t, vm.Read, decodeErr.Instruction.Op)
+		assert.Equal(t, vm.String, decodeErr.Instruction.Operand)
+		assert.Equal(t, program.Instructions[decodeErr.PC], decodeErr.Instruction)
+	}
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestDecodeErrorFromTarget(t *testing.T) {
+	// The program sets a string, but the target for arrayField is an array of longs
+	schema := strings.Replace(testRecordSchema, `{"type": "array", "items": "long"}`, `"string"`, 1)
+	program, err := compiler.CompileSchemaBytes([]byte(schema), []byte(schema))
+	assert.Nil(t, err)
+
+	buf := encodeTestRecord(testRecordFixture)
+	buf = appendString(buf[:len(buf)-1], "not an array")
+	err = evalBoth(t, program, buf, newTestRecord)
+
+	var decodeErr *vm.DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, "TestRecord.arrayField", decodeErr.Path)
+		assert.Equal(t, vm.Set, decodeErr.Instruction.Op)
+		assert.Equal(t, "Unsupported operation", decodeErr.Err.Error())
+	}
+	assert.True(t, errors.Is(err, types.ErrUnsupportedOperation))
+}
+
+func TestDecodeErrorBytesLengthOutOfRange(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := appendLong(nil, 1)
+	buf = appendLong(buf, 2)
+	buf = appendFloat(buf, 3)
+	buf = appendDouble(buf, 4)
+	buf = appendString(buf, "five")
+	// A bytes length too large to allocate
+	buf = appendLong(buf, 1<<60)
+
+	err := vm.Eval(bytes.NewReader(buf), program, &testRecord{})
+	var decodeErr *vm.DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, "TestRecord.bytesField", decodeErr.Path)
+		assert.Equal(t, "bytes length out of range: 1152921504606846976", decodeErr.Err.Error())
+	}
+	_, err = vm.EvalBytes(buf, program, &testRecord{})
+	assert.True(t, errors.As(err, &decodeErr))
+}
+
+func TestDecodeErrorUnverifiedProgram(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		instructions []vm.Instruction
+		err          string
+	}{
+		{"halt error out of range", []vm.Instruction{{Op: vm.Halt, Operand: 2}}, "Halt error 2 out of range"},
+		{"negative jump", []vm.Instruction{{Op: vm.Jump, Operand: -10}}, "Runtime panic: runtime error: index out of range"},
+	} {
+		// Without the Strict option the program isn't verified, but it still fails with an error rather than a panic
+		program := &vm.Program{Instructions: c.instructions}
+		err := vm.Eval(bytes.NewReader(nil), program, &testRecord{})
+		var decodeErr *vm.DecodeError
+		if assert.True(t, errors.As(err, &decodeErr), c.name) {
+			assert.True(t, strings.HasPrefix(decodeErr.Err.Error(), c.err), "%v: %v", c.name, decodeErr.Err)
+		}
+	}
+}
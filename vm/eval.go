@@ -19,54 +19,380 @@ type stackFrame struct {
 	Condition bool
 }
 
-func Eval(r io.Reader, program *Program, target types.Field) (err error) {
-	var pc int
+// An Option changes how a program is evaluated
+type Option func(*evalConfig)
+
+type evalConfig struct {
+	aliasInput     bool
+	trace          io.Writer
+	observer       Observer
+	maxBytesLength int64
+	maxItems       int64
+	maxDepth       int
+	maxTotalBytes  int64
+	strict         bool
+}
+
+// AliasInput makes EvalBytes decode bytes, strings and fixed values without copying them,
+// so they share memory with the input buffer. The buffer must not be modified while the decoded values are in use.
+// It has no effect on Eval.
+func AliasInput() Option {
+	return func(c *evalConfig) {
+		c.aliasInput = true
+	}
+}
+
+func newEvalConfig(opts []Option) evalConfig {
+	var c evalConfig
+	for _, o := range opts {
+		o(&c)
+	}
+	return c
+}
+
+// Eval runs the program to decode a single datum from r into target.
+// It allocates a new Evaluator, so to decode many values with the same program use NewEvaluator instead.
+func Eval(r io.Reader, program *Program, target types.Field, opts ...Option) error {
+	return NewEvaluator(program, opts...).Eval(r, target)
+}
+
+// EvalBytes runs the program to decode a single datum from the start of buf into target,
+// and returns the number of bytes consumed. Values are decoded directly out of buf, which avoids
+// the per-value allocations of reading from an io.Reader.
+func EvalBytes(buf []byte, program *Program, target types.Field, opts ...Option) (int, error) {
+	return NewEvaluator(program, opts...).EvalBytes(buf, target)
+}
+
+// Evaluator runs a program over one datum after another, reusing its state between them.
+// The VM runs on an explicit stack of frames rather than the Go stack, so deeply nested input
+// only grows the frame stack, which is kept for the next datum. Once the stack has grown to fit
+// the input, decoding doesn't allocate anything beyond the values set on the target.
+// The depth of the stack is limited by the MaxDepth option.
+//
+// An Evaluator must not be used from multiple goroutines at the same time.
+type Evaluator struct {
+	e          evaluator
+	ioSource   ioSource
+	byteSource byteSource
+	// The error from verifying the program in Strict mode, returned for every datum
+	verifyErr error
+}
+
+// NewEvaluator returns an Evaluator for the program, which applies opts to every datum it decodes
+func NewEvaluator(program *Program, opts ...Option) *Evaluator {
+	config := newEvalConfig(opts)
+	v := &Evaluator{e: evaluator{program: program, config: config, hooks: newHooks(config, program)}}
+	if config.strict {
+		v.verifyErr = program.Verify()
+	}
+	return v
+}
+
+// Eval decodes a single datum from r into target, like the Eval function
+func (v *Evaluator) Eval(r io.Reader, target types.Field) error {
+	if v.verifyErr != nil {
+		return v.verifyErr
+	}
+	v.ioSource.reset(r, newLimits(v.e.config))
+	return v.e.eval(&v.ioSource, target)
+}
+
+// EvalBytes decodes a single datum from the start of buf into target and returns the number of bytes consumed,
+// like the EvalBytes function
+func (v *Evaluator) EvalBytes(buf []byte, target types.Field) (int, error) {
+	if v.verifyErr != nil {
+		return 0, v.verifyErr
+	}
+	v.byteSource.reset(buf, v.e.config.aliasInput, newLimits(v.e.config))
+	err := v.e.eval(&v.byteSource, target)
+	return v.byteSource.pos, err
+}
+
+// evaluator holds the state shared by every frame while a program runs
+type evaluator struct {
+	r       source
+	program *Program
+	config  evalConfig
+	pc      int
+	hooks   *hooks
+	// The stack of frames, with the current frame on top. It's kept between runs.
+	frames []frame
+}
+
+// frame is the state of one level of the input, from the instruction which entered it to the one which returns from it
+type frame struct {
+	stackFrame
+	target types.Field
+	// The nesting depth of the input, and the index of the array item being read in a PushLoop frame
+	depth int
+	item  int64
+	// The value of the Long register saved by a PushLoop, restored when the loop body returns
+	loop int64
+	// The number of items declared so far in the current array or map, and the index of the next item
+	items, index int64
+	// The instruction which created the frame, which names it in the path of errors,
+	// and the address to continue from if it was a Call
+	entered  Instruction
+	returnPC int
+}
+
+func (e *evaluator) eval(r source, target types.Field) (err error) {
+	e.r = r
+	e.pc = 0
+	if e.hooks != nil {
+		e.hooks.reset()
+	}
 	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("Panic at pc %v - %v, name: %v", pc, r, program.Instructions[pc].String())
+		// The sources and the verifier should catch bad input and programs, but a panic which gets past them
+		// is still returned as an error rather than taking down the process
+		if p := recover(); p != nil {
+			err = e.unwind(e.panicError(p))
+		}
+		if e.hooks != nil {
+			e.hooks.flushBytes(r)
+		}
+		// Drop the references to the input and the target, so they can be garbage collected
+		e.r = nil
+		for len(e.frames) > 0 {
+			e.pop()
 		}
 	}()
+	return e.run(target)
+}
+
+// panicError wraps a value recovered from a panic in a DecodeError. The pc may be past the end of
+// an unverified program, in which case the error has no instruction.
+func (e *evaluator) panicError(p interface{}) error {
+	err := fmt.Errorf("Runtime panic: %v", p)
+	if e.pc < 0 || e.pc >= len(e.program.Instructions) {
+		return &DecodeError{Offset: e.r.valueOffset(), PC: e.pc, Err: err}
+	}
+	return e.newError(err)
+}
+
+// push adds a frame to the top of the stack and returns it
+func (e *evaluator) push(entered Instruction, target types.Field, depth int, item int64) *frame {
+	e.frames = append(e.frames, frame{target: target, depth: depth, item: item, entered: entered, returnPC: e.pc})
+	return &e.frames[len(e.frames)-1]
+}
+
+// pop removes the frame on top of the stack, clearing it so the space kept for the stack
+// doesn't hold on to decoded values
+func (e *evaluator) pop() {
+	n := len(e.frames) - 1
+	e.frames[n] = frame{}
+	e.frames = e.frames[:n]
+}
+
+// ret returns from the current frame to the one below it, and returns that frame.
+// The pc is left on the instruction which returned, unless the frame was entered with a Call.
+func (e *evaluator) ret() *frame {
+	returned := &e.frames[len(e.frames)-1]
+	op, returnPC := returned.entered.Op, returned.returnPC
+	e.pop()
+	f := &e.frames[len(e.frames)-1]
+	switch op {
+	case Call:
+		e.pc = returnPC
+	case PushLoop, BlockStart, SkipBlockStart, BlockEnd:
+		f.index++
+		f.Long = f.loop
+	}
+	return f
+}
+
+// unwind adds the path to an error from the frame on top of the stack, naming each frame after the
+// instruction which entered it
+func (e *evaluator) unwind(err error) error {
+	for i := len(e.frames) - 1; i > 0; i-- {
+		child, parent := &e.frames[i], &e.frames[i-1]
+		switch child.entered.Op {
+		case Enter:
+			err = withPath(err, child.entered.Name)
+		case AppendArray:
+			err = withPath(err, fmt.Sprintf("[%v]", parent.item))
+		case AppendMap:
+			err = withPath(err, fmt.Sprintf("[%q]", parent.String))
+		case Call:
+			// Only the top-level record is part of the path, nested records are named by their field
+			if parent.depth == 0 {
+				err = withPath(err, child.entered.Name)
+			}
+		}
+	}
+	return err
+}
 
-	return evalInner(r, program, target, &pc)
+// checkDepth is called before a Call or a loop is pushed, which nest the input one level deeper
+func (e *evaluator) checkDepth(depth int) error {
+	if e.config.maxDepth > 0 && depth >= e.config.maxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: int64(e.config.maxDepth), Value: int64(depth + 1)}
+	}
+	return nil
 }
 
-func evalInner(r io.Reader, program *Program, target types.Field, pc *int) (err error) {
-	var loop int64
+// countItems adds the item count of a block to the total for the current array or map.
+// A zero count ends the array or map and resets the total.
+func (e *evaluator) countItems(count int64, items *int64) error {
+	if count == 0 {
+		*items = 0
+		return nil
+	}
+	if e.config.maxItems <= 0 {
+		return nil
+	}
+	if count < 0 {
+		count = -count
+	}
+	*items += count
+	if count < 0 || *items > e.config.maxItems {
+		return &LimitError{Limit: "MaxItems", Max: e.config.maxItems, Value: *items}
+	}
+	return nil
+}
 
-	frame := stackFrame{}
-	for ; *pc < len(program.Instructions); *pc++ {
-		inst := program.Instructions[*pc]
+// readSet reads a value of type t from the wire into the frame and sets target to it.
+// Fixed values are set as bytes.
+func readSet(r source, f *stackFrame, target types.Field, t int) (err error) {
+	switch t {
+	case Null:
+		break
+	case Boolean:
+		if f.Boolean, err = r.readBool(); err == nil {
+			err = target.DeserializeBoolean(f.Boolean)
+		}
+		break
+	case Int:
+		if f.Int, err = r.readInt(); err == nil {
+			err = target.DeserializeInt(f.Int)
+		}
+		break
+	case Long:
+		if f.Long, err = r.readLong(); err == nil {
+			err = target.DeserializeLong(f.Long)
+		}
+		break
+	case Float:
+		if f.Float, err = r.readFloat(); err == nil {
+			err = target.DeserializeFloat(f.Float)
+		}
+		break
+	case Double:
+		if f.Double, err = r.readDouble(); err == nil {
+			err = target.DeserializeDouble(f.Double)
+		}
+		break
+	case Bytes:
+		if f.Bytes, err = r.readBytes(); err == nil {
+			err = target.DeserializeBytes(f.Bytes)
+		}
+		break
+	case String:
+		if f.String, err = r.readString(); err == nil {
+			err = target.DeserializeString(f.String)
+		}
+		break
+	default:
+		if f.Bytes, err = r.readFixed(t - 11); err == nil {
+			err = target.DeserializeBytes(f.Bytes)
+		}
+		break
+	}
+	return err
+}
+
+// startBlock reads the item count of the next array or map block into the Long register, counting it towards
+// the item limit, and returns true if it's zero because the array or map has ended. The byte size of a block is
+// discarded, or if skip is set, the whole block is skipped and the next one is read.
+func (e *evaluator) startBlock(r source, f *frame, skip bool) (bool, error) {
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return false, err
+		}
+		f.Long = count
+		if count == 0 {
+			f.index = 0
+		}
+		if err = e.countItems(count, &f.items); err != nil || count >= 0 {
+			return count == 0, err
+		}
+
+		size, err := r.readLong()
+		if err != nil {
+			return false, err
+		}
+		if !skip {
+			f.Long = -count
+			return false, nil
+		}
+		if size < 0 {
+			return false, fmt.Errorf("block size out of range: %d", size)
+		}
+		if err = r.skipFixed(size); err != nil {
+			return false, err
+		}
+	}
+}
+
+// pushLoop pushes the item count in the Long register onto the loop stack, so the loop body runs in a new frame
+func (e *evaluator) pushLoop(inst Instruction, f *frame) (*frame, error) {
+	if err := e.checkDepth(f.depth); err != nil {
+		return f, err
+	}
+	f.loop = f.Long
+	return e.push(inst, f.target, f.depth+1, f.index), nil
+}
+
+// run executes the program until the bottom frame returns
+func (e *evaluator) run(target types.Field) (err error) {
+	r := e.r
+	program := e.program
+	f := e.push(Instruction{}, target, 0, 0)
+	for ; ; e.pc++ {
+		if e.pc >= len(program.Instructions) {
+			// Running off the end of the program returns from every frame in turn
+			if len(e.frames) == 1 {
+				return nil
+			}
+			f = e.ret()
+			continue
+		}
+		inst := program.Instructions[e.pc]
+		if e.hooks != nil {
+			e.hooks.before(e, inst, &f.stackFrame)
+		}
 		switch inst.Op {
 		case Read:
 			switch inst.Operand {
 			case Null:
 				break
 			case Boolean:
-				frame.Boolean, err = readBool(r)
+				f.Boolean, err = r.readBool()
 				break
 			case Int:
-				frame.Int, err = readInt(r)
+				f.Int, err = r.readInt()
 				break
 			case Long:
-				frame.Long, err = readLong(r)
+				f.Long, err = r.readLong()
 				break
 			case UnusedLong:
-				_, err = readLong(r)
+				_, err = r.readLong()
 				break
 			case Float:
-				frame.Float, err = readFloat(r)
+				f.Float, err = r.readFloat()
 				break
 			case Double:
-				frame.Double, err = readDouble(r)
+				f.Double, err = r.readDouble()
 				break
 			case Bytes:
-				frame.Bytes, err = readBytes(r)
+				f.Bytes, err = r.readBytes()
 				break
 			case String:
-				frame.String, err = readString(r)
+				f.String, err = r.readString()
 				break
 			default:
-				frame.Bytes, err = readFixed(r, inst.Operand-11)
+				f.Bytes, err = r.readFixed(inst.Operand - 11)
 				break
 			}
 			break
@@ -75,107 +401,246 @@ func evalInner(r io.Reader, program *Program, target types.Field, pc *int) (err
 			case Null:
 				break
 			case Boolean:
-				target.DeserializeBoolean(frame.Boolean)
+				err = f.target.DeserializeBoolean(f.Boolean)
 				break
 			case Int:
-				target.DeserializeInt(frame.Int)
+				err = f.target.DeserializeInt(f.Int)
 				break
 			case Long:
-				target.DeserializeLong(frame.Long)
+				err = f.target.DeserializeLong(f.Long)
 				break
 			case Float:
-				target.DeserializeFloat(frame.Float)
+				err = f.target.DeserializeFloat(f.Float)
 				break
 			case Double:
-				target.DeserializeDouble(frame.Double)
+				err = f.target.DeserializeDouble(f.Double)
 				break
 			case Bytes:
-				target.DeserializeBytes(frame.Bytes)
+				err = f.target.DeserializeBytes(f.Bytes)
 				break
 			case String:
-				target.DeserializeString(frame.String)
+				err = f.target.DeserializeString(f.String)
 				break
 			}
 			break
+		case Skip:
+			switch inst.Operand {
+			case Null:
+				break
+			case Boolean:
+				_, err = r.readBool()
+				break
+			case Int:
+				_, err = r.readInt()
+				break
+			case Long, UnusedLong:
+				_, err = r.readLong()
+				break
+			case Float:
+				err = r.skipFixed(4)
+				break
+			case Double:
+				err = r.skipFixed(8)
+				break
+			case Bytes, String:
+				err = r.skipBytes()
+				break
+			default:
+				err = r.skipFixed(int64(inst.Operand - 11))
+				break
+			}
+			break
+		case ReadSet:
+			err = readSet(r, &f.stackFrame, f.target, inst.Operand)
+			break
+		case IntToLong:
+			f.Long = int64(f.Int)
+			break
+		case IntToFloat:
+			f.Float = float32(f.Int)
+			break
+		case IntToDouble:
+			f.Double = float64(f.Int)
+			break
+		case LongToFloat:
+			f.Float = float32(f.Long)
+			break
+		case LongToDouble:
+			f.Double = float64(f.Long)
+			break
+		case FloatToDouble:
+			f.Double = float64(f.Float)
+			break
+		case StringToBytes:
+			f.Bytes = []byte(f.String)
+			break
+		case BytesToString:
+			f.String = string(f.Bytes)
+			break
 		case SetDefault:
-			target.SetDefault(inst.Operand)
+			err = f.target.SetDefault(inst.Operand)
+			break
+		case Jump:
+			e.pc = inst.Operand - 1
+			break
+		case EvalGreater:
+			f.Condition = (f.Long > int64(inst.Operand))
+			break
+		case EvalEqual:
+			f.Condition = (f.Long == int64(inst.Operand))
+			break
+		case CondJump:
+			if f.Condition {
+				e.pc = inst.Operand - 1
+			}
+			break
+		case AddLong:
+			f.Long += int64(inst.Operand)
+			break
+		case SetLong:
+			f.Long = int64(inst.Operand)
+			break
+		case MultLong:
+			f.Long *= int64(inst.Operand)
+			break
+		case SkipBlock:
+			var size int64
+			if size, err = r.readLong(); err != nil {
+				break
+			}
+			if size < 0 {
+				err = fmt.Errorf("block size out of range: %d", size)
+				break
+			}
+			err = r.skipFixed(size)
+			break
+		case ReadCount:
+			if f.Long, err = r.readLong(); err == nil {
+				if f.Long == 0 {
+					f.index = 0
+				}
+				err = e.countItems(f.Long, &f.items)
+			}
 			break
 		case Enter:
-			*pc += 1
-			if err = evalInner(r, program, target.Get(inst.Operand), pc); err != nil {
-				return err
+			var field types.Field
+			if field, err = f.target.Get(inst.Operand); err != nil {
+				break
 			}
+			f = e.push(inst, field, f.depth, 0)
 			break
-		case Exit:
-			target.Finalize()
-			return nil
 		case AppendArray:
-			*pc += 1
-			if err = evalInner(r, program, target.AppendArray(), pc); err != nil {
-				return err
+			var field types.Field
+			if field, err = f.target.AppendArray(); err != nil {
+				break
 			}
+			f = e.push(inst, field, f.depth, 0)
 			break
 		case AppendMap:
-			*pc += 1
-			if err = evalInner(r, program, target.AppendMap(frame.String), pc); err != nil {
-				return err
+			var field types.Field
+			if field, err = f.target.AppendMap(f.String); err != nil {
+				break
 			}
+			f = e.push(inst, field, f.depth, 0)
 			break
 		case Call:
-			curr := *pc
-			*pc = inst.Operand
-			if err = evalInner(r, program, target, pc); err != nil {
-				return err
+			if err = e.checkDepth(f.depth); err != nil {
+				break
 			}
-			*pc = curr
+			f = e.push(inst, f.target, f.depth+1, 0)
+			// The loop moves on to the operand
+			e.pc = inst.Operand - 1
 			break
-		case Return:
-			return nil
-		case Jump:
-			*pc = inst.Operand - 1
+		case PushLoop:
+			f, err = e.pushLoop(inst, f)
 			break
-		case EvalGreater:
-			frame.Condition = (frame.Long > int64(inst.Operand))
+		case BlockStart, SkipBlockStart:
+			var done bool
+			if done, err = e.startBlock(r, f, inst.Op == SkipBlockStart); err != nil {
+				break
+			}
+			if done {
+				e.pc = inst.Operand - 1
+				break
+			}
+			f, err = e.pushLoop(inst, f)
 			break
-		case EvalEqual:
-			frame.Condition = (frame.Long == int64(inst.Operand))
+		case BlockEnd:
+			if len(e.frames) == 1 {
+				return nil
+			}
+			f = e.ret()
+			f.Long--
+			if f.Long == 0 {
+				e.pc = inst.Operand - 1
+				break
+			}
+			// The loop moves on to the instruction after the BlockStart
+			e.pc = inst.Operand
+			f, err = e.pushLoop(inst, f)
 			break
-		case CondJump:
-			if frame.Condition {
-				*pc = inst.Operand - 1
+		case ReadSetField:
+			index, t := splitFieldOperand(inst.Operand)
+			var field types.Field
+			if field, err = f.target.Get(index); err != nil {
+				break
 			}
+			if err = readSet(r, &f.stackFrame, field, t); err != nil {
+				break
+			}
+			err = field.Finalize()
 			break
-		case AddLong:
-			frame.Long += int64(inst.Operand)
+		case PushDepth:
+			if err = e.checkDepth(f.depth); err != nil {
+				break
+			}
+			// Frames pushed from here on are nested one level deeper, until the PopDepth
+			f.depth++
 			break
-		case SetLong:
-			frame.Long = int64(inst.Operand)
+		case PopDepth:
+			f.depth--
 			break
-		case MultLong:
-			frame.Long *= int64(inst.Operand)
+		case Exit:
+			if err = f.target.Finalize(); err != nil {
+				break
+			}
+			if len(e.frames) == 1 {
+				return nil
+			}
+			f = e.ret()
 			break
-		case PushLoop:
-			loop = frame.Long
-			*pc += 1
-			if err = evalInner(r, program, target, pc); err != nil {
-				return err
+		case Return, PopLoop:
+			if len(e.frames) == 1 {
+				return nil
 			}
-			frame.Long = loop
+			f = e.ret()
 			break
-		case PopLoop:
-			return nil
 		case Halt:
 			if inst.Operand == 0 {
-				return nil
+				if len(e.frames) == 1 {
+					return nil
+				}
+				f = e.ret()
+				break
 			}
-			return fmt.Errorf("Runtime error: %v, frame: %v, pc: %v", program.Errors[inst.Operand-1], frame, pc)
+			if inst.Operand < 0 || inst.Operand > len(program.Errors) {
+				err = fmt.Errorf("Halt error %v out of range", inst.Operand)
+				break
+			}
+			err = fmt.Errorf("Runtime error: %v", program.Errors[inst.Operand-1])
+			break
 		default:
-			return fmt.Errorf("Unknown instruction %v", program.Instructions[*pc])
+			err = fmt.Errorf("Unknown instruction %v", inst)
+			break
 		}
 
 		if err != nil {
-			return err
+			err = e.newError(err)
+			if inst.Op == ReadSetField {
+				// The field has no frame, so it's added to the path here
+				err = withPath(err, inst.Name)
+			}
+			return e.unwind(err)
 		}
 	}
-	return nil
 }
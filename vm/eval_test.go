@@ -0,0 +1,321 @@
+package vm_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRecordSchema = `{
+	"type": "record",
+	"name": "TestRecord",
+	"fields": [
+		{"name": "intField", "type": "int"},
+		{"name": "longField", "type": "long"},
+		{"name": "floatField", "type": "float"},
+		{"name": "doubleField", "type": "double"},
+		{"name": "stringField", "type": "string"},
+		{"name": "bytesField", "type": "bytes"},
+		{"name": "arrayField", "type": {"type": "array", "items": "long"}}
+	]
+}`
+
+// A hand-written equivalent of the structs generated for testRecordSchema
+type testRecord struct {
+	IntField    int32
+	LongField   int64
+	FloatField  float32
+	DoubleField float64
+	StringField string
+	BytesField  []byte
+	ArrayField  []int64
+}
+
+func (_ *testRecord) DeserializeBoolean(v bool) error   { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeInt(v int32) error      { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeLong(v int64) error     { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeFloat(v float32) error  { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeBytes(v []byte) error   { return types.ErrUnsupportedOperation }
+func (_ *testRecord) DeserializeString(v string) error  { return types.ErrUnsupportedOperation }
+func (r *testRecord) Get(i int) (types.Field, error) {
+	switch i {
+	case 0:
+		return (*types.Int)(&r.IntField), nil
+	case 1:
+		return (*types.Long)(&r.LongField), nil
+	case 2:
+		return (*types.Float)(&r.FloatField), nil
+	case 3:
+		return (*types.Double)(&r.DoubleField), nil
+	case 4:
+		return (*types.String)(&r.StringField), nil
+	case 5:
+		return (*types.Bytes)(&r.BytesField), nil
+	case 6:
+		return (*longArray)(&r.ArrayField), nil
+	}
+	return nil, types.ErrUnknownFieldIndex
+}
+func (_ *testRecord) SetDefault(i int) error { return types.ErrUnsupportedOperation }
+func (_ *testRecord) AppendMap(key string) (types.Field, error) {
+	return nil, types.ErrUnsupportedOperation
+}
+func (_ *testRecord) AppendArray() (types.Field, error) { return nil, types.ErrUnsupportedOperation }
+func (_ *testRecord) Finalize() error                   { return nil }
+
+type longArray []int64
+
+func (_ *longArray) DeserializeBoolean(v bool) error   { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeInt(v int32) error      { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeLong(v int64) error     { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeFloat(v float32) error  { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeDouble(v float64) error { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeBytes(v []byte) error   { return types.ErrUnsupportedOperation }
+func (_ *longArray) DeserializeString(v string) error  { return types.ErrUnsupportedOperation }
+func (_ *longArray) Get(i int) (types.Field, error)    { return nil, types.ErrUnsupportedOperation }
+func (_ *longArray) SetDefault(i int) error            { return types.ErrUnsupportedOperation }
+func (_ *longArray) AppendMap(key string) (types.Field, error) {
+	return nil, types.ErrUnsupportedOperation
+}
+func (r *longArray) AppendArray() (types.Field, error) {
+	*r = append(*r, 0)
+	return (*types.Long)(&(*r)[len(*r)-1]), nil
+}
+func (_ *longArray) Finalize() error { return nil }
+
+// Helpers to build Avro binary data for the tests
+
+func appendLong(b []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(b, scratch[:binary.PutVarint(scratch[:], v)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLong(b, int64(len(s))), s...)
+}
+
+func appendFloat(b []byte, f float32) []byte {
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(f))
+	return append(b, scratch[:]...)
+}
+
+func appendDouble(b []byte, f float64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+	return append(b, scratch[:]...)
+}
+
+func encodeTestRecord(r *testRecord) []byte {
+	b := appendLong(nil, int64(r.IntField))
+	b = appendLong(b, r.LongField)
+	b = appendFloat(b, r.FloatField)
+	b = appendDouble(b, r.DoubleField)
+	b = appendString(b, r.StringField)
+	b = appendString(b, string(r.BytesField))
+	if len(r.ArrayField) > 0 {
+		b = appendLong(b, int64(len(r.ArrayField)))
+		for _, v := range r.ArrayField {
+			b = appendLong(b, v)
+		}
+	}
+	return appendLong(b, 0)
+}
+
+var testRecordFixture = &testRecord{
+	IntField:    -12,
+	LongField:   1 << 40,
+	FloatField:  1.5,
+	DoubleField: -3.25,
+	StringField: "hello",
+	BytesField:  []byte{1, 2, 3},
+	ArrayField:  []int64{7, -8, 9},
+}
+
+func compileTestRecord(t testing.TB) *vm.Program {
+	program, err := compiler.CompileSchemaBytes([]byte(testRecordSchema), []byte(testRecordSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestEvalBytes(t *testing.T) {
+	program := compileTestRecord(t)
+	encoded := encodeTestRecord(testRecordFixture)
+
+	// Decoding from a slice with trailing data only consumes the first datum
+	buf := append(append([]byte{}, encoded...), 0xff, 0xff)
+	record := &testRecord{}
+	n, err := vm.EvalBytes(buf, program, record)
+	assert.Nil(t, err)
+	assert.Equal(t, len(encoded), n)
+	assert.Equal(t, testRecordFixture, record)
+
+	// Decoding from an io.Reader gives the same result
+	fromReader := &testRecord{}
+	assert.Nil(t, vm.Eval(bytes.NewReader(encoded), program, fromReader))
+	assert.Equal(t, record, fromReader)
+}
+
+func TestEvalBytesCopiesByDefault(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	record := &testRecord{}
+	_, err := vm.EvalBytes(buf, program, record)
+	assert.Nil(t, err)
+
+	for i := range buf {
+		buf[i] = 0
+	}
+	assert.Equal(t, testRecordFixture, record)
+}
+
+func TestEvalBytesAliasInput(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	record := &testRecord{}
+	_, err := vm.EvalBytes(buf, program, record, vm.AliasInput())
+	assert.Nil(t, err)
+	assert.Equal(t, testRecordFixture, record)
+
+	// The bytes field points into the input buffer
+	offset := bytes.Index(buf, []byte{1, 2, 3})
+	buf[offset] = 42
+	assert.Equal(t, []byte{42, 2, 3}, record.BytesField)
+}
+
+func TestEvalBytesAliasInputAppend(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	record := &testRecord{}
+	_, err := vm.EvalBytes(buf, program, record, vm.AliasInput())
+	assert.Nil(t, err)
+	assert.Equal(t, len(record.BytesField), cap(record.BytesField))
+
+	// Appending to the aliased value copies it, leaving the array field after it in the input untouched
+	record.BytesField = append(record.BytesField, 0xff, 0xff, 0xff)
+	again := &testRecord{}
+	_, err = vm.EvalBytes(buf, program, again, vm.AliasInput())
+	assert.Nil(t, err)
+	assert.Equal(t, testRecordFixture, again)
+}
+
+func TestEvalBytesTruncated(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	for i := 0; i < len(buf); i++ {
+		_, err := vm.EvalBytes(buf[:i], program, &testRecord{})
+		assert.NotNil(t, err, "Expected an error decoding %v bytes", i)
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	program := compileTestRecord(b)
+	buf := encodeTestRecord(testRecordFixture)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.Eval(bytes.NewReader(buf), program, &testRecord{})
+	}
+}
+
+func BenchmarkEvalBytes(b *testing.B) {
+	program := compileTestRecord(b)
+	buf := encodeTestRecord(testRecordFixture)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.EvalBytes(buf, program, &testRecord{})
+	}
+}
+
+func BenchmarkEvalBytesAliasInput(b *testing.B) {
+	program := compileTestRecord(b)
+	buf := encodeTestRecord(testRecordFixture)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.EvalBytes(buf, program, &testRecord{}, vm.AliasInput())
+	}
+}
+
+const linkedListSchema = `{"type": "record", "name": "Node", "fields": [{"name": "next", "type": ["null", "Node"]}]}`
+
+// encodeLinkedList encodes a list of Nodes nested n deep
+func encodeLinkedList(n int) []byte {
+	buf := bytes.Repeat([]byte{2}, n)
+	return append(buf, 0)
+}
+
+func TestEvaluatorReuse(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+	evaluator := vm.NewEvaluator(program)
+
+	for i := 0; i < 3; i++ {
+		record := &testRecord{}
+		n, err := evaluator.EvalBytes(buf, record)
+		assert.Nil(t, err)
+		assert.Equal(t, len(buf), n)
+		assert.Equal(t, testRecordFixture, record)
+
+		// A failed datum doesn't affect the next one
+		_, err = evaluator.EvalBytes(buf[:len(buf)-2], &testRecord{})
+		assert.NotNil(t, err)
+
+		record = &testRecord{}
+		assert.Nil(t, evaluator.Eval(bytes.NewReader(buf), record))
+		assert.Equal(t, testRecordFixture, record)
+	}
+}
+
+func TestEvaluatorDoesNotAllocate(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+	evaluator := vm.NewEvaluator(program, vm.AliasInput())
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := evaluator.EvalBytes(buf, types.Discard{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestEvalDeepRecursion(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(linkedListSchema), []byte(linkedListSchema))
+	assert.Nil(t, err)
+	buf := encodeLinkedList(100000)
+
+	n, err := vm.EvalBytes(buf, program, types.Discard{})
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Nil(t, vm.Eval(bytes.NewReader(buf), program, types.Discard{}))
+}
+
+func TestEvaluatorMaxDepth(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(linkedListSchema), []byte(linkedListSchema))
+	assert.Nil(t, err)
+	evaluator := vm.NewEvaluator(program, vm.MaxDepth(10))
+
+	_, err = evaluator.EvalBytes(encodeLinkedList(9), types.Discard{})
+	assert.Nil(t, err)
+
+	_, err = evaluator.EvalBytes(encodeLinkedList(10), types.Discard{})
+	limitErr := asLimitError(t, err)
+	assert.Equal(t, "MaxDepth", limitErr.Limit)
+	assert.Equal(t, int64(11), limitErr.Value)
+}
@@ -0,0 +1,81 @@
+package vm
+
+import (
+	"fmt"
+)
+
+// MaxBytesLength limits the length of bytes and string values. Lengths are checked before
+// the value is allocated, so a corrupt length prefix can't exhaust memory.
+func MaxBytesLength(n int64) Option {
+	return func(c *evalConfig) {
+		c.maxBytesLength = n
+	}
+}
+
+// MaxItems limits the total number of items in a single array or map, across all of its blocks.
+func MaxItems(n int64) Option {
+	return func(c *evalConfig) {
+		c.maxItems = n
+	}
+}
+
+// MaxDepth limits how deeply records, arrays and maps can be nested in the input.
+// A top-level record has a depth of 1.
+func MaxDepth(n int) Option {
+	return func(c *evalConfig) {
+		c.maxDepth = n
+	}
+}
+
+// MaxTotalBytes limits the number of bytes consumed decoding a single datum.
+func MaxTotalBytes(n int64) Option {
+	return func(c *evalConfig) {
+		c.maxTotalBytes = n
+	}
+}
+
+// LimitError is the cause of the DecodeError returned by Eval and EvalBytes when the input exceeds
+// one of the limits set with the Max* options.
+type LimitError struct {
+	// The name of the limit that was exceeded, like "MaxBytesLength"
+	Limit string
+	// The configured limit
+	Max int64
+	// The value found in the input
+	Value int64
+	// The path of the field being decoded, the same as DecodeError.Path
+	Path string
+}
+
+func (e *LimitError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%v exceeded: %v > %v", e.Limit, e.Value, e.Max)
+	}
+	return fmt.Sprintf("%v exceeded at %v: %v > %v", e.Limit, e.Path, e.Value, e.Max)
+}
+
+// limits holds the checks applied by the sources as values are read
+type limits struct {
+	maxBytesLength int64
+	maxTotalBytes  int64
+}
+
+func newLimits(c evalConfig) limits {
+	return limits{maxBytesLength: c.maxBytesLength, maxTotalBytes: c.maxTotalBytes}
+}
+
+// checkLength is called with the length of a bytes or string value starting at offset, before it's allocated
+func (l limits) checkLength(size, offset int64) error {
+	if l.maxBytesLength > 0 && size > l.maxBytesLength {
+		return &LimitError{Limit: "MaxBytesLength", Max: l.maxBytesLength, Value: size}
+	}
+	return l.checkTotal(offset + size)
+}
+
+// checkTotal is called with the offset the input would reach after the next read
+func (l limits) checkTotal(end int64) error {
+	if l.maxTotalBytes > 0 && end > l.maxTotalBytes {
+		return &LimitError{Limit: "MaxTotalBytes", Max: l.maxTotalBytes, Value: end}
+	}
+	return nil
+}
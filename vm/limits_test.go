@@ -0,0 +1,137 @@
+package vm_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tagsRecordSchema = `{
+	"type": "record",
+	"name": "TagsRecord",
+	"fields": [
+		{"name": "tags", "type": {"type": "array", "items": "string"}}
+	]
+}`
+
+// The tags tests only check errors, so they decode into types.Discard
+func newDiscard() types.Field {
+	return types.Discard{}
+}
+
+// evalBoth decodes buf with both Eval and EvalBytes, and checks they return the same error
+func evalBoth(t *testing.T, program *vm.Program, buf []byte, newTarget func() types.Field, opts ...vm.Option) error {
+	_, bytesErr := vm.EvalBytes(buf, program, newTarget(), opts...)
+	readerErr := vm.Eval(bytes.NewReader(buf), program, newTarget(), opts...)
+	assert.Equal(t, bytesErr, readerErr)
+	return bytesErr
+}
+
+// asLimitError unwraps the LimitError from the DecodeError returned by Eval
+func asLimitError(t *testing.T, err error) *vm.LimitError {
+	var limitErr *vm.LimitError
+	assert.True(t, errors.As(err, &limitErr), "Expected a LimitError, got %v", err)
+	return limitErr
+}
+
+func newTestRecord() types.Field {
+	return &testRecord{}
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	err := evalBoth(t, program, buf, newTestRecord,
+		vm.MaxBytesLength(5), vm.MaxItems(3), vm.MaxDepth(2), vm.MaxTotalBytes(int64(len(buf))))
+	assert.Nil(t, err)
+}
+
+func TestMaxBytesLength(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	err := evalBoth(t, program, buf, newTestRecord, vm.MaxBytesLength(4))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxBytesLength", Max: 4, Value: 5, Path: "TestRecord.stringField"}, asLimitError(t, err))
+}
+
+func TestMaxBytesLengthChecksBeforeAllocating(t *testing.T) {
+	program := compileTestRecord(t)
+	// A string claiming to be 1TB long, with no data behind it
+	buf := appendLong(nil, 0)
+	buf = appendLong(buf, 0)
+	buf = appendFloat(buf, 0)
+	buf = appendDouble(buf, 0)
+	buf = appendLong(buf, 1<<40)
+
+	err := vm.Eval(bytes.NewReader(buf), program, &testRecord{}, vm.MaxBytesLength(1<<20))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxBytesLength", Max: 1 << 20, Value: 1 << 40, Path: "TestRecord.stringField"}, asLimitError(t, err))
+}
+
+func TestMaxItems(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	err := evalBoth(t, program, buf, newTestRecord, vm.MaxItems(2))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxItems", Max: 2, Value: 3, Path: "TestRecord.arrayField"}, asLimitError(t, err))
+}
+
+func TestMaxItemsAcrossBlocks(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(tagsRecordSchema), []byte(tagsRecordSchema))
+	assert.Nil(t, err)
+
+	// Three blocks of two items each, the second with a negative count and a byte size
+	buf := appendLong(nil, 2)
+	buf = appendString(appendString(buf, "a"), "b")
+	buf = appendLong(appendLong(buf, -2), 4)
+	buf = appendString(appendString(buf, "c"), "d")
+	buf = appendLong(buf, 2)
+	buf = appendString(appendString(buf, "e"), "f")
+	buf = appendLong(buf, 0)
+
+	assert.Nil(t, evalBoth(t, program, buf, newDiscard, vm.MaxItems(6)))
+	err = evalBoth(t, program, buf, newDiscard, vm.MaxItems(5))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxItems", Max: 5, Value: 6, Path: "TagsRecord.tags"}, asLimitError(t, err))
+}
+
+func TestLimitErrorArrayPath(t *testing.T) {
+	program, err := compiler.CompileSchemaBytes([]byte(tagsRecordSchema), []byte(tagsRecordSchema))
+	assert.Nil(t, err)
+
+	buf := appendLong(nil, 3)
+	buf = appendString(buf, "short")
+	buf = appendString(buf, "short")
+	buf = appendString(buf, "much too long")
+	buf = appendLong(buf, 0)
+
+	err = evalBoth(t, program, buf, newDiscard, vm.MaxBytesLength(5))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxBytesLength", Max: 5, Value: 13, Path: "TagsRecord.tags[2]"}, asLimitError(t, err))
+}
+
+func TestMaxDepth(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	// The record is at depth 1 and the items of arrayField at depth 2
+	err := evalBoth(t, program, buf, newTestRecord, vm.MaxDepth(1))
+	assert.Equal(t, &vm.LimitError{Limit: "MaxDepth", Max: 1, Value: 2, Path: "TestRecord.arrayField"}, asLimitError(t, err))
+}
+
+func TestMaxTotalBytes(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	for i := int64(1); i < int64(len(buf)); i++ {
+		err := evalBoth(t, program, buf, newTestRecord, vm.MaxTotalBytes(i))
+		if limitErr := asLimitError(t, err); limitErr != nil {
+			assert.Equal(t, "MaxTotalBytes", limitErr.Limit)
+			assert.True(t, limitErr.Value > i)
+		}
+	}
+}
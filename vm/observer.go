@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"io"
+)
+
+// An Observer is notified of what the VM does while it evaluates a program, for building metrics,
+// sampling tracers or reports of which fields are never populated. Callbacks run synchronously on the
+// decoding goroutine, so they should be cheap. Embed NopObserver to implement only some of them.
+type Observer interface {
+	// Instruction is called before each instruction is executed
+	Instruction(pc int, inst Instruction)
+	// EnterField is called with the Enter, AppendArray, AppendMap or ReadSetField instruction which entered a field,
+	// an array item or a map value of the target. Enter and ReadSetField instructions are named after the reader field,
+	// except for union branches which are unnamed.
+	EnterField(inst Instruction)
+	// ExitField is called with the same instruction once the field has been decoded
+	ExitField(inst Instruction)
+	// BytesRead is called with the number of bytes consumed by each instruction which reads from the input
+	BytesRead(n int64)
+	// DefaultApplied is called with the SetDefault instruction for a reader field the writer doesn't have.
+	// The operand is the index of the field and the name is the field name.
+	DefaultApplied(inst Instruction)
+	// FieldSkipped is called from Program.SkippedFields for a writer field the reader doesn't have,
+	// before its value is read and discarded
+	FieldSkipped(field SkippedField)
+}
+
+// NopObserver implements every Observer callback as a no-op
+type NopObserver struct{}
+
+func (_ NopObserver) Instruction(pc int, inst Instruction) {}
+func (_ NopObserver) EnterField(inst Instruction)          {}
+func (_ NopObserver) ExitField(inst Instruction)           {}
+func (_ NopObserver) BytesRead(n int64)                    {}
+func (_ NopObserver) DefaultApplied(inst Instruction)      {}
+func (_ NopObserver) FieldSkipped(field SkippedField)      {}
+
+// Observe attaches an Observer to the evaluation
+func Observe(o Observer) Option {
+	return func(c *evalConfig) {
+		c.observer = o
+	}
+}
+
+// hooks reports the progress of an evaluator to the Observer and trace writer.
+// It's only allocated when one of them is set, so the evaluation loop costs a single nil check otherwise.
+type hooks struct {
+	observer Observer
+	trace    io.Writer
+	// The input offset when bytes were last reported
+	offset int64
+	// The instructions which entered the fields being decoded, matched to their Exit instructions
+	fields []Instruction
+	// The program's SkippedFields by their Start, and the previous instruction executed
+	skipped map[int][]SkippedField
+	lastPC  int
+	lastOp  Op
+	// Set to the ReadSetField before the current instruction, which is exited once its bytes are reported
+	exitField *Instruction
+}
+
+func newHooks(config evalConfig, program *Program) *hooks {
+	if config.observer == nil && config.trace == nil {
+		return nil
+	}
+	h := &hooks{observer: config.observer, trace: config.trace}
+	if config.observer != nil && len(program.SkippedFields) > 0 {
+		h.skipped = make(map[int][]SkippedField)
+		for _, f := range program.SkippedFields {
+			h.skipped[f.Start] = append(h.skipped[f.Start], f)
+		}
+	}
+	return h
+}
+
+// reset clears the progress of the previous datum, keeping the space allocated for fields
+func (h *hooks) reset() {
+	h.offset = 0
+	h.fields = h.fields[:0]
+	h.lastPC = -1
+	h.exitField = nil
+}
+
+// before is called before each instruction is executed
+func (h *hooks) before(e *evaluator, inst Instruction, frame *stackFrame) {
+	if h.trace != nil {
+		e.traceInstruction(inst, frame)
+	}
+	if h.observer == nil {
+		return
+	}
+	h.flushBytes(e.r)
+	h.exitReadSetField()
+	h.observer.Instruction(e.pc, inst)
+	h.fieldsSkipped(e.pc)
+	switch inst.Op {
+	case Enter, AppendArray, AppendMap:
+		h.fields = append(h.fields, inst)
+		h.observer.EnterField(inst)
+	case ReadSetField:
+		h.observer.EnterField(inst)
+		h.exitField = &e.program.Instructions[e.pc]
+	case Exit:
+		if n := len(h.fields); n > 0 {
+			field := h.fields[n-1]
+			h.fields = h.fields[:n-1]
+			h.observer.ExitField(field)
+		}
+	case SetDefault:
+		h.observer.DefaultApplied(inst)
+	}
+	h.lastPC, h.lastOp = e.pc, inst.Op
+}
+
+// exitReadSetField reports the exit of a field decoded by the previous instruction, a ReadSetField
+func (h *hooks) exitReadSetField() {
+	if h.exitField != nil {
+		h.observer.ExitField(*h.exitField)
+		h.exitField = nil
+	}
+}
+
+// fieldsSkipped reports the skipped fields which start at pc. Arriving at the start from inside the rest
+// of the record is a loop jumping back, unless it's a recursive call or a return.
+func (h *hooks) fieldsSkipped(pc int) {
+	for _, f := range h.skipped[pc] {
+		if h.lastOp == Call || h.lastOp == Return || h.lastPC < f.Start || h.lastPC >= f.End {
+			h.observer.FieldSkipped(f)
+		}
+	}
+}
+
+// flushBytes reports the bytes consumed since the last call, which were read by the previous instruction
+func (h *hooks) flushBytes(r source) {
+	if h.observer == nil {
+		return
+	}
+	if offset := r.offset(); offset > h.offset {
+		h.observer.BytesRead(offset - h.offset)
+		h.offset = offset
+	}
+}
@@ -0,0 +1,129 @@
+package vm_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+	"github.com/clear-street/gogen-avro/vm/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingObserver keeps a log of the field events, and totals for the rest
+type recordingObserver struct {
+	vm.NopObserver
+	events       []string
+	instructions int
+	bytes        int64
+}
+
+func (o *recordingObserver) Instruction(pc int, inst vm.Instruction) { o.instructions++ }
+func (o *recordingObserver) BytesRead(n int64)                       { o.bytes += n }
+func (o *recordingObserver) EnterField(inst vm.Instruction) {
+	o.events = append(o.events, "enter "+inst.Name)
+}
+func (o *recordingObserver) ExitField(inst vm.Instruction) {
+	o.events = append(o.events, "exit "+inst.Name)
+}
+func (o *recordingObserver) DefaultApplied(inst vm.Instruction) {
+	o.events = append(o.events, fmt.Sprintf("default %v %v", inst.Operand, inst.Name))
+}
+func (o *recordingObserver) FieldSkipped(field vm.SkippedField) {
+	o.events = append(o.events, fmt.Sprintf("skip %v %v", field.Index, field.Name))
+}
+
+// defaultingRecord accepts SetDefault for the field replacing bytesField in the reader schema
+type defaultingRecord struct {
+	testRecord
+}
+
+func (_ *defaultingRecord) SetDefault(i int) error { return nil }
+
+func TestObserver(t *testing.T) {
+	reader := strings.Replace(testRecordSchema, `{"name": "bytesField", "type": "bytes"}`, `{"name": "newField", "type": "bytes", "default": ""}`, 1)
+	program, err := compiler.CompileSchemaBytes([]byte(testRecordSchema), []byte(reader))
+	assert.Nil(t, err)
+	buf := encodeTestRecord(testRecordFixture)
+
+	observer := &recordingObserver{}
+	_, err = vm.EvalBytes(buf, program, &defaultingRecord{}, vm.Observe(observer))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"default 5 newField",
+		"enter intField", "exit intField",
+		"enter longField", "exit longField",
+		"enter floatField", "exit floatField",
+		"enter doubleField", "exit doubleField",
+		"enter stringField", "exit stringField",
+		"skip 5 bytesField",
+		"enter arrayField",
+		"enter ArrayLong", "exit ArrayLong",
+		"enter ArrayLong", "exit ArrayLong",
+		"enter ArrayLong", "exit ArrayLong",
+		"exit arrayField",
+	}, observer.events)
+	assert.Equal(t, int64(len(buf)), observer.bytes)
+	assert.True(t, observer.instructions > len(observer.events))
+}
+
+func TestObserverSkippedLoops(t *testing.T) {
+	// Skipping an array loops back to the first instruction of the field, and skipping a null has no instructions
+	writer := `{"type": "record", "name": "R", "fields": [
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "nothing", "type": "null"},
+		{"name": "more", "type": {"type": "array", "items": "long"}},
+		{"name": "kept", "type": "long"}
+	]}`
+	reader := `{"type": "record", "name": "R", "fields": [{"name": "kept", "type": "long"}]}`
+	program, err := compiler.CompileSchemaBytes([]byte(writer), []byte(reader))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(program.SkippedFields))
+
+	buf := appendLong(nil, 1)
+	buf = appendString(buf, "a")
+	buf = appendLong(buf, 1)
+	buf = appendString(buf, "b")
+	buf = appendLong(buf, 0)
+	buf = appendLong(appendLong(appendLong(buf, 2), 1), 2)
+	buf = appendLong(appendLong(appendLong(buf, 1), 3), 0)
+	buf = appendLong(buf, 4)
+
+	observer := &recordingObserver{}
+	n, err := vm.EvalBytes(buf, program, types.Discard{}, vm.Observe(observer))
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, []string{
+		"skip 0 tags",
+		"skip 1 nothing",
+		"skip 2 more",
+		"enter kept", "exit kept",
+	}, observer.events)
+}
+
+func TestObserverWithTrace(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	observer := &recordingObserver{}
+	trace := &bytes.Buffer{}
+	_, err := vm.EvalBytes(buf, program, &testRecord{}, vm.Observe(observer), vm.Trace(trace))
+	assert.Nil(t, err)
+	assert.Equal(t, observer.instructions, strings.Count(trace.String(), "\n"))
+	assert.Equal(t, int64(len(buf)), observer.bytes)
+}
+
+func BenchmarkEvalBytesObserver(b *testing.B) {
+	program := compileTestRecord(b)
+	buf := encodeTestRecord(testRecordFixture)
+	observer := vm.Observe(vm.NopObserver{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.EvalBytes(buf, program, &testRecord{}, observer)
+	}
+}
@@ -60,8 +60,68 @@ const (
 
 	// Pop the top of the loop stack and store the value in the Long register
 	PopLoop
+
+	// Read the item count at the start of an array or map block into the Long register.
+	// The counts are totalled against the MaxItems limit until a zero count ends the array or map.
+	ReadCount
+
+	// Read a value of the operand type from the wire and discard it. Bytes, strings and fixed values are skipped without being allocated.
+	Skip
+
+	// Read a value of the operand type from the wire into the frame, and set the current target to it.
+	// Fixed values are set as bytes.
+	ReadSet
+
+	// Convert the value in one register of the frame to the type of another, for the promotions allowed by the spec.
+	// The converted value is set with a Set of the new type.
+	IntToLong
+	IntToFloat
+	IntToDouble
+	LongToFloat
+	LongToDouble
+	FloatToDouble
+	StringToBytes
+	BytesToString
+
+	// Read the size in bytes of an array or map block, which follows a negative item count, and skip past the block
+	SkipBlock
+
+	// Start the next block of an array or map, like the ReadCount, PushLoop and surrounding instructions the compiler emits.
+	// Read the item count, discarding the byte size if there is one, and push the count onto the loop stack.
+	// If the count is zero the array or map has ended, and the PC moves to the operand instead.
+	BlockStart
+
+	// Start the next block of an array or map the reader doesn't have, like BlockStart. Blocks with a byte size are
+	// skipped over without running the loop body.
+	SkipBlockStart
+
+	// End an item of the block started by the BlockStart at the operand address. Pop the loop stack and decrement the count.
+	// If items are left, push the count back and move the PC to the instruction after the BlockStart,
+	// otherwise move the PC to the BlockStart to read the next block.
+	BlockEnd
+
+	// Read a value from the wire and set the field of the current target to it, like an Enter, ReadSet and Exit.
+	// The operand packs the field index and the value type, see FieldOperand.
+	ReadSetField
+
+	// Count one more level of nesting towards the MaxDepth limit, like a Call, without leaving the current frame.
+	// The optimizer puts it in place of the Call of a record it inlines.
+	PushDepth
+
+	// Undo a PushDepth, like the Return of an inlined record
+	PopDepth
 )
 
+// FieldOperand returns the operand of a ReadSetField, which packs the index of the field with the type of the value
+func FieldOperand(index, t int) int {
+	return index<<4 | t
+}
+
+// splitFieldOperand returns the field index and value type packed by FieldOperand
+func splitFieldOperand(operand int) (int, int) {
+	return operand >> 4, operand & 0xf
+}
+
 func (o Op) String() string {
 	switch o {
 	case Read:
@@ -102,6 +162,42 @@ func (o Op) String() string {
 		return "pop_loop"
 	case SetLong:
 		return "set_long"
+	case ReadCount:
+		return "read_count"
+	case Skip:
+		return "skip"
+	case ReadSet:
+		return "read_set"
+	case IntToLong:
+		return "int_to_long"
+	case IntToFloat:
+		return "int_to_float"
+	case IntToDouble:
+		return "int_to_double"
+	case LongToFloat:
+		return "long_to_float"
+	case LongToDouble:
+		return "long_to_double"
+	case FloatToDouble:
+		return "float_to_double"
+	case StringToBytes:
+		return "string_to_bytes"
+	case BytesToString:
+		return "bytes_to_string"
+	case SkipBlock:
+		return "skip_block"
+	case BlockStart:
+		return "block_start"
+	case SkipBlockStart:
+		return "skip_block_start"
+	case BlockEnd:
+		return "block_end"
+	case ReadSetField:
+		return "read_set_field"
+	case PushDepth:
+		return "push_depth"
+	case PopDepth:
+		return "pop_depth"
 	}
 	return "Unknown"
 }
@@ -10,6 +10,21 @@ type Program struct {
 
 	// A list of errors that can be triggered by halt(x), where x is the index in this array + 1
 	Errors []string
+
+	// The writer fields the reader doesn't have, in the order they're decoded.
+	// They're only used to report Observer.FieldSkipped, so no instructions are spent on them.
+	SkippedFields []SkippedField
+}
+
+// A SkippedField is a writer field which the program reads and discards
+type SkippedField struct {
+	// The pc of the first instruction after the fields before it, and the end of the instructions
+	// which decode the rest of its record. A jump to Start from inside the range is a loop in a later field,
+	// not a new record.
+	Start, End int
+	// The index of the field in the writer schema and its name
+	Index int
+	Name  string
 }
 
 func (p *Program) String() string {
@@ -29,5 +44,9 @@ func (p *Program) String() string {
 	for i, err := range p.Errors {
 		s += fmt.Sprintf("Error %v:\t%v\n", i+1, err)
 	}
+
+	for _, f := range p.SkippedFields {
+		s += fmt.Sprintf("Skipped %v:\t%v (%v - %v)\n", f.Index, f.Name, f.Start, f.End)
+	}
 	return s
 }
@@ -8,6 +8,6 @@ import (
 func BenchmarkReadBool(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r := bytes.NewBuffer([]byte{1})
-		readBool(r)
+		newIOSource(r, limits{}).readBool()
 	}
 }
@@ -4,132 +4,190 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"unsafe"
 )
 
 type ByteReader interface {
 	ReadByte() (byte, error)
 }
 
-func readBool(r io.Reader) (bool, error) {
-	var b byte
-	var err error
-	if br, ok := r.(ByteReader); ok {
-		b, err = br.ReadByte()
-	} else {
-		bs := make([]byte, 1)
-		_, err = io.ReadFull(r, bs)
-		if err != nil {
-			return false, err
-		}
-		b = bs[0]
-	}
-	return b == 1, nil
+// source is the input the VM decodes primitives from.
+// ioSource reads from an io.Reader, byteSource indexes directly into a byte slice.
+type source interface {
+	readBool() (bool, error)
+	readInt() (int32, error)
+	readLong() (int64, error)
+	readFloat() (float32, error)
+	readDouble() (float64, error)
+	readBytes() ([]byte, error)
+	readString() (string, error)
+	readFixed(size int) ([]byte, error)
+	// Skip a length-prefixed bytes or string value, or a fixed number of bytes, without allocating them
+	skipBytes() error
+	skipFixed(size int64) error
+	// The offset in the input of the start of the last value read
+	valueOffset() int64
+	// The number of bytes consumed so far
+	offset() int64
 }
 
-func readBytes(r io.Reader) ([]byte, error) {
-	size, err := readLong(r)
-	if err != nil {
-		return nil, err
+// ioSource reads from an io.Reader one value at a time, without reading ahead,
+// so the reader is positioned right after the datum once the program halts.
+type ioSource struct {
+	r  io.Reader
+	br ByteReader
+	// The number of bytes consumed so far, and the offset of the last value read
+	n     int64
+	start int64
+	limits
+	// Scratch space for fixed-size reads, to avoid allocating a buffer for every value
+	scratch [8]byte
+}
+
+func newIOSource(r io.Reader, l limits) *ioSource {
+	s := &ioSource{}
+	s.reset(r, l)
+	return s
+}
+
+// reset starts reading a new datum from r
+func (s *ioSource) reset(r io.Reader, l limits) {
+	*s = ioSource{r: r, limits: l}
+	s.br, _ = r.(ByteReader)
+}
+
+func (s *ioSource) readByte() (byte, error) {
+	if err := s.checkTotal(s.n + 1); err != nil {
+		return 0, err
 	}
-	if size == 0 {
-		return []byte{}, nil
+	if s.br != nil {
+		b, err := s.br.ReadByte()
+		if err == nil {
+			s.n++
+		}
+		return b, err
 	}
-	bb := make([]byte, size)
-	_, err = io.ReadFull(r, bb)
-	return bb, err
+	if err := s.readFull(s.scratch[:1]); err != nil {
+		return 0, err
+	}
+	return s.scratch[0], nil
 }
 
-func readDouble(r io.Reader) (float64, error) {
-	buf := make([]byte, 8)
-	_, err := io.ReadFull(r, buf)
-	if err != nil {
-		return 0, err
+func (s *ioSource) readFull(b []byte) error {
+	if err := s.checkTotal(s.n + int64(len(b))); err != nil {
+		return err
 	}
-	bits := binary.LittleEndian.Uint64(buf)
-	val := math.Float64frombits(bits)
-	return val, nil
+	n, err := io.ReadFull(s.r, b)
+	s.n += int64(n)
+	return err
+}
+
+func (s *ioSource) valueOffset() int64 {
+	return s.start
 }
 
-func readFloat(r io.Reader) (float32, error) {
-	buf := make([]byte, 4)
-	_, err := io.ReadFull(r, buf)
+func (s *ioSource) offset() int64 {
+	return s.n
+}
+
+func (s *ioSource) readBool() (bool, error) {
+	s.start = s.n
+	b, err := s.readByte()
 	if err != nil {
-		return 0, err
+		return false, err
 	}
-	bits := binary.LittleEndian.Uint32(buf)
-	val := math.Float32frombits(bits)
-	return val, nil
+	return b == 1, nil
 }
 
-func readInt(r io.Reader) (int32, error) {
+func (s *ioSource) readInt() (int32, error) {
+	s.start = s.n
 	var v int
-	var b byte
-	var err error
-	if br, ok := r.(ByteReader); ok {
-		for shift := uint(0); ; shift += 7 {
-			if b, err = br.ReadByte(); err != nil {
-				return 0, err
-			}
-			v |= int(b&127) << shift
-			if b&128 == 0 {
-				break
-			}
+	for shift := uint(0); ; shift += 7 {
+		b, err := s.readByte()
+		if err != nil {
+			return 0, err
 		}
-	} else {
-		buf := make([]byte, 1)
-		for shift := uint(0); ; shift += 7 {
-			if _, err := io.ReadFull(r, buf); err != nil {
-				return 0, err
-			}
-			b = buf[0]
-			v |= int(b&127) << shift
-			if b&128 == 0 {
-				break
-			}
+		v |= int(b&127) << shift
+		if b&128 == 0 {
+			break
 		}
 	}
 	datum := (int32(v>>1) ^ -int32(v&1))
 	return datum, nil
 }
 
-func readLong(r io.Reader) (int64, error) {
+func (s *ioSource) readLong() (int64, error) {
+	s.start = s.n
 	var v uint64
-	var b byte
-	var err error
-	if br, ok := r.(ByteReader); ok {
-		for shift := uint(0); ; shift += 7 {
-			if b, err = br.ReadByte(); err != nil {
-				return 0, err
-			}
-			v |= uint64(b&127) << shift
-			if b&128 == 0 {
-				break
-			}
+	for shift := uint(0); ; shift += 7 {
+		b, err := s.readByte()
+		if err != nil {
+			return 0, err
 		}
-	} else {
-		buf := make([]byte, 1)
-		for shift := uint(0); ; shift += 7 {
-			if _, err = io.ReadFull(r, buf); err != nil {
-				return 0, err
-			}
-			b = buf[0]
-			v |= uint64(b&127) << shift
-			if b&128 == 0 {
-				break
-			}
+		v |= uint64(b&127) << shift
+		if b&128 == 0 {
+			break
 		}
 	}
 	datum := (int64(v>>1) ^ -int64(v&1))
 	return datum, nil
 }
 
-func readString(r io.Reader) (string, error) {
-	len, err := readLong(r)
+func (s *ioSource) readFloat() (float32, error) {
+	s.start = s.n
+	if err := s.readFull(s.scratch[:4]); err != nil {
+		return 0, err
+	}
+	bits := binary.LittleEndian.Uint32(s.scratch[:4])
+	return math.Float32frombits(bits), nil
+}
+
+func (s *ioSource) readDouble() (float64, error) {
+	s.start = s.n
+	if err := s.readFull(s.scratch[:8]); err != nil {
+		return 0, err
+	}
+	bits := binary.LittleEndian.Uint64(s.scratch[:8])
+	return math.Float64frombits(bits), nil
+}
+
+func (s *ioSource) readBytes() ([]byte, error) {
+	size, err := s.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		if err := s.checkLength(size, s.n); err != nil {
+			return nil, err
+		}
+	}
+
+	// makeslice can fail depending on available memory, so bytes are limited like strings
+	if size < 0 || size > math.MaxInt32 {
+		return nil, fmt.Errorf("bytes length out of range: %d", size)
+	}
+	if size == 0 {
+		return []byte{}, nil
+	}
+	bb := make([]byte, size)
+	err = s.readFull(bb)
+	return bb, err
+}
+
+func (s *ioSource) readString() (string, error) {
+	len, err := s.readLong()
 	if err != nil {
 		return "", err
 	}
 
+	if len >= 0 {
+		if err := s.checkLength(len, s.n); err != nil {
+			return "", err
+		}
+	}
+
 	// makeslice can fail depending on available memory.
 	// We arbitrarily limit string size to sane default (~2.2GB).
 	if len < 0 || len > math.MaxInt32 {
@@ -141,15 +199,240 @@ func readString(r io.Reader) (string, error) {
 	}
 
 	bb := make([]byte, len)
-	_, err = io.ReadFull(r, bb)
-	if err != nil {
+	if err := s.readFull(bb); err != nil {
 		return "", err
 	}
 	return string(bb), nil
 }
 
-func readFixed(r io.Reader, size int) ([]byte, error) {
+func (s *ioSource) readFixed(size int) ([]byte, error) {
+	s.start = s.n
+	if size < 0 || size > math.MaxInt32 {
+		return nil, fmt.Errorf("fixed size out of range: %d", size)
+	}
+	if err := s.checkTotal(s.n + int64(size)); err != nil {
+		return nil, err
+	}
 	bb := make([]byte, size)
-	_, err := io.ReadFull(r, bb)
+	err := s.readFull(bb)
 	return bb, err
 }
+
+func (s *ioSource) skipBytes() error {
+	size, err := s.readLong()
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("bytes length out of range: %d", size)
+	}
+	if err := s.checkLength(size, s.n); err != nil {
+		return err
+	}
+	return s.discard(size)
+}
+
+func (s *ioSource) skipFixed(size int64) error {
+	s.start = s.n
+	return s.discard(size)
+}
+
+// discard reads and throws away n bytes, returning io.ErrUnexpectedEOF if the input ends first
+func (s *ioSource) discard(n int64) error {
+	if err := s.checkTotal(s.n + n); err != nil {
+		return err
+	}
+	copied, err := io.CopyN(ioutil.Discard, s.r, n)
+	s.n += copied
+	if err == io.EOF && copied > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// byteSource decodes values directly out of a byte slice.
+// If alias is set, bytes, strings and fixed values share memory with buf instead of being copied.
+type byteSource struct {
+	buf   []byte
+	pos   int
+	start int
+	alias bool
+	limits
+	// Set if buf was cut short at the MaxTotalBytes limit, so running out of input is a limit error
+	truncated bool
+}
+
+// reset starts decoding a new datum from the start of buf
+func (s *byteSource) reset(buf []byte, alias bool, l limits) {
+	*s = byteSource{buf: buf, alias: alias, limits: l}
+	if l.maxTotalBytes > 0 && int64(len(buf)) > l.maxTotalBytes {
+		s.buf = buf[:l.maxTotalBytes]
+		s.truncated = true
+	}
+}
+
+// eof returns the error for a value starting at start which needs the input up to end,
+// past the end of buf
+func (s *byteSource) eof(start, end int) error {
+	if s.truncated {
+		return s.checkTotal(int64(end))
+	}
+	if start == len(s.buf) {
+		return io.EOF
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// next returns the next n bytes of the input and advances past them
+func (s *byteSource) next(n int) ([]byte, error) {
+	if n > len(s.buf)-s.pos {
+		return nil, s.eof(s.pos, s.pos+n)
+	}
+	// Cap the slice, so appending to an aliased value can't overwrite the rest of the input
+	b := s.buf[s.pos : s.pos+n : s.pos+n]
+	s.pos += n
+	return b, nil
+}
+
+func (s *byteSource) valueOffset() int64 {
+	return int64(s.start)
+}
+
+func (s *byteSource) offset() int64 {
+	return int64(s.pos)
+}
+
+func (s *byteSource) readBool() (bool, error) {
+	s.start = s.pos
+	if s.pos >= len(s.buf) {
+		return false, s.eof(s.pos, s.pos+1)
+	}
+	b := s.buf[s.pos]
+	s.pos++
+	return b == 1, nil
+}
+
+func (s *byteSource) readVarint() (uint64, error) {
+	var v uint64
+	for shift, i := uint(0), s.pos; ; shift, i = shift+7, i+1 {
+		if i >= len(s.buf) {
+			return 0, s.eof(s.pos, i+1)
+		}
+		b := s.buf[i]
+		v |= uint64(b&127) << shift
+		if b&128 == 0 {
+			s.pos = i + 1
+			return v, nil
+		}
+	}
+}
+
+func (s *byteSource) readInt() (int32, error) {
+	s.start = s.pos
+	v, err := s.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return (int32(v>>1) ^ -int32(v&1)), nil
+}
+
+func (s *byteSource) readLong() (int64, error) {
+	s.start = s.pos
+	v, err := s.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return (int64(v>>1) ^ -int64(v&1)), nil
+}
+
+func (s *byteSource) readFloat() (float32, error) {
+	s.start = s.pos
+	b, err := s.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (s *byteSource) readDouble() (float64, error) {
+	s.start = s.pos
+	b, err := s.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// readSized reads a length-prefixed value without copying it
+func (s *byteSource) readSized() ([]byte, error) {
+	size, err := s.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("length out of range: %d", size)
+	}
+	if err := s.checkLength(size, int64(s.pos)); err != nil {
+		return nil, err
+	}
+	if size > int64(len(s.buf)-s.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return s.next(int(size))
+}
+
+func (s *byteSource) readBytes() ([]byte, error) {
+	b, err := s.readSized()
+	if err != nil || s.alias {
+		return b, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+func (s *byteSource) readString() (string, error) {
+	b, err := s.readSized()
+	if err != nil {
+		return "", err
+	}
+	if s.alias {
+		return unsafeString(b), nil
+	}
+	return string(b), nil
+}
+
+func (s *byteSource) readFixed(size int) ([]byte, error) {
+	s.start = s.pos
+	if size < 0 {
+		return nil, fmt.Errorf("fixed size out of range: %d", size)
+	}
+	b, err := s.next(size)
+	if err != nil || s.alias {
+		return b, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+func (s *byteSource) skipBytes() error {
+	_, err := s.readSized()
+	return err
+}
+
+func (s *byteSource) skipFixed(size int64) error {
+	s.start = s.pos
+	if size < 0 {
+		return fmt.Errorf("fixed size out of range: %d", size)
+	}
+	if size > int64(len(s.buf)-s.pos) {
+		return s.eof(s.pos, s.pos+int(size))
+	}
+	s.pos += int(size)
+	return nil
+}
+
+// unsafeString returns a string sharing memory with b
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
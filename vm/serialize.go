@@ -0,0 +1,180 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ProgramFormatVersion is the version of the binary encoding produced by MarshalBinary.
+// It must be incremented whenever the encoding or the meaning of the instruction set changes,
+// including when opcodes are added, so programs serialized by another gogen-avro are rejected with
+// a version mismatch instead of being misinterpreted.
+// Version 2 added ReadCount, 3 SkipField, 4 Skip and ReadSet, 5 the type conversion opcodes, 6 SkipBlock,
+// 7 replaced SkipField with the skipped field table, 8 added the block and ReadSetField opcodes and 9 PushDepth and PopDepth.
+const ProgramFormatVersion = 9
+
+// The number of opcodes in ProgramFormatVersion. A test checks it against opCount, so adding an opcode
+// without bumping the version fails.
+const formatOpCount = 37
+
+var programMagic = []byte{'G', 'A', 'D', 'G'}
+
+// The number of opcodes known to this version of the VM, used to reject unknown instructions on load
+const opCount = int(PopDepth) + 1
+
+// MarshalBinary encodes the program in a stable binary format:
+// the magic bytes "GADG", the format version, the instructions, the error table and the skipped field table.
+// Integers are zig-zag varints and strings are length-prefixed, as in the Avro binary encoding.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.Write(programMagic)
+	writeVarint(buf, ProgramFormatVersion)
+	writeVarint(buf, int64(len(p.Instructions)))
+	for _, inst := range p.Instructions {
+		writeVarint(buf, int64(inst.Op))
+		writeVarint(buf, int64(inst.Operand))
+		writeVarintString(buf, inst.Name)
+	}
+	writeVarint(buf, int64(len(p.Errors)))
+	for _, e := range p.Errors {
+		writeVarintString(buf, e)
+	}
+	writeVarint(buf, int64(len(p.SkippedFields)))
+	for _, f := range p.SkippedFields {
+		writeVarint(buf, int64(f.Start))
+		writeVarint(buf, int64(f.End))
+		writeVarint(buf, int64(f.Index))
+		writeVarintString(buf, f.Name)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a program encoded by MarshalBinary, replacing the contents of p.
+func (p *Program) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(programMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, programMagic) {
+		return fmt.Errorf("Invalid program - bad magic %q", magic)
+	}
+
+	version, err := binary.ReadVarint(r)
+	if err != nil {
+		return err
+	}
+	if version != ProgramFormatVersion {
+		return fmt.Errorf("Unsupported program format version %v, expected %v", version, ProgramFormatVersion)
+	}
+
+	count, err := readVarintLength(r)
+	if err != nil {
+		return err
+	}
+	instructions := make([]Instruction, count)
+	for i := range instructions {
+		op, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		operand, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		name, err := readVarintString(r)
+		if err != nil {
+			return err
+		}
+		instructions[i] = Instruction{Op: Op(op), Operand: int(operand), Name: name}
+	}
+
+	count, err = readVarintLength(r)
+	if err != nil {
+		return err
+	}
+	errs := make([]string, count)
+	for i := range errs {
+		if errs[i], err = readVarintString(r); err != nil {
+			return err
+		}
+	}
+
+	count, err = readVarintLength(r)
+	if err != nil {
+		return err
+	}
+	var skipped []SkippedField
+	if count > 0 {
+		skipped = make([]SkippedField, count)
+	}
+	for i := range skipped {
+		var values [3]int64
+		for j := range values {
+			if values[j], err = binary.ReadVarint(r); err != nil {
+				return err
+			}
+		}
+		name, err := readVarintString(r)
+		if err != nil {
+			return err
+		}
+		skipped[i] = SkippedField{Start: int(values[0]), End: int(values[1]), Index: int(values[2]), Name: name}
+	}
+
+	if r.Len() != 0 {
+		return fmt.Errorf("Invalid program - %v trailing bytes", r.Len())
+	}
+
+	p.Instructions = instructions
+	p.Errors = errs
+	p.SkippedFields = skipped
+	return nil
+}
+
+// LoadProgram decodes a program serialized with MarshalBinary and checks that it can be
+// run by this version of the VM - the format version matches and the program passes Verify.
+func LoadProgram(data []byte) (*Program, error) {
+	p := &Program{}
+	if err := p.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func writeVarint(buf *bytes.Buffer, v int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(scratch[:], v)
+	buf.Write(scratch[:n])
+}
+
+func writeVarintString(buf *bytes.Buffer, s string) {
+	writeVarint(buf, int64(len(s)))
+	buf.WriteString(s)
+}
+
+func readVarintLength(r *bytes.Reader) (int, error) {
+	l, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, err
+	}
+	// Every encoded element takes at least one byte, so a longer length can't be valid
+	if l < 0 || l > int64(r.Len()) {
+		return 0, fmt.Errorf("Invalid program - length %v out of range", l)
+	}
+	return int(l), nil
+}
+
+func readVarintString(r *bytes.Reader) (string, error) {
+	l, err := readVarintLength(r)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
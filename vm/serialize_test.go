@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var serializeFixture = &Program{
+	Instructions: []Instruction{
+		{Op: Read, Operand: Long, Name: "Long"},
+		{Op: MultLong, Operand: -1},
+		{Op: Halt, Operand: 1, Name: "Halt"},
+	},
+	Errors:        []string{"Unsupported type for union"},
+	SkippedFields: []SkippedField{{Start: 1, End: 2, Index: 3, Name: "dropped"}},
+}
+
+func TestProgramRoundTrip(t *testing.T) {
+	serialized, err := serializeFixture.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded, err := LoadProgram(serialized)
+	assert.Nil(t, err)
+	assert.Equal(t, serializeFixture, loaded)
+}
+
+func TestLoadProgramChecksVersion(t *testing.T) {
+	serialized, err := serializeFixture.MarshalBinary()
+	assert.Nil(t, err)
+
+	serialized[len(programMagic)] = 0x7e
+	_, err = LoadProgram(serialized)
+	assert.NotNil(t, err)
+}
+
+func TestLoadProgramChecksOpcodes(t *testing.T) {
+	p := &Program{Instructions: []Instruction{{Op: Op(1000)}}}
+	serialized, err := p.MarshalBinary()
+	assert.Nil(t, err)
+
+	_, err = LoadProgram(serialized)
+	assert.NotNil(t, err)
+}
+
+func TestLoadProgramVerifies(t *testing.T) {
+	for _, p := range []*Program{
+		{Instructions: []Instruction{{Op: Jump, Operand: 5}}},
+		{Instructions: []Instruction{{Op: Enter, Operand: 0}, {Op: Halt, Operand: 0}}},
+		{Instructions: []Instruction{{Op: Halt, Operand: 0}}, SkippedFields: []SkippedField{{Start: 0, End: 2}}},
+	} {
+		serialized, err := p.MarshalBinary()
+		assert.Nil(t, err)
+
+		_, err = LoadProgram(serialized)
+		assert.Equal(t, p.Verify(), err)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestLoadProgramRejectsTruncated(t *testing.T) {
+	serialized, err := serializeFixture.MarshalBinary()
+	assert.Nil(t, err)
+
+	_, err = LoadProgram(serialized[:len(serialized)-3])
+	assert.NotNil(t, err)
+}
+
+func TestProgramFormatVersionTracksOpcodes(t *testing.T) {
+	assert.Equal(t, formatOpCount, opCount, "the opcodes changed - bump ProgramFormatVersion and formatOpCount")
+}
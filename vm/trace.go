@@ -0,0 +1,19 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+)
+
+// Trace writes a line to w for every instruction the VM executes, before it's executed,
+// with the registers of the current frame and the number of bytes consumed so far.
+// It's meant for debugging programs against real data, and makes evaluation much slower.
+func Trace(w io.Writer) Option {
+	return func(c *evalConfig) {
+		c.trace = w
+	}
+}
+
+func (e *evaluator) traceInstruction(inst Instruction, frame *stackFrame) {
+	fmt.Fprintf(e.config.trace, "%v:\t%v\t%+v\tconsumed: %v\n", e.pc, inst, *frame, e.r.offset())
+}
@@ -0,0 +1,18 @@
+package types
+
+// Discard is a Field which accepts every operation and keeps nothing. It's a target for running a program
+// only for its side effects, like tracing or validating data, without the structs generated for the reader schema.
+type Discard struct{}
+
+func (_ Discard) DeserializeBoolean(v bool) error     { return nil }
+func (_ Discard) DeserializeInt(v int32) error        { return nil }
+func (_ Discard) DeserializeLong(v int64) error       { return nil }
+func (_ Discard) DeserializeFloat(v float32) error    { return nil }
+func (_ Discard) DeserializeDouble(v float64) error   { return nil }
+func (_ Discard) DeserializeBytes(v []byte) error     { return nil }
+func (_ Discard) DeserializeString(v string) error    { return nil }
+func (d Discard) Get(i int) (Field, error)            { return d, nil }
+func (_ Discard) SetDefault(i int) error              { return nil }
+func (d Discard) AppendMap(key string) (Field, error) { return d, nil }
+func (d Discard) AppendArray() (Field, error)         { return d, nil }
+func (_ Discard) Finalize() error                     { return nil }
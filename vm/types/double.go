@@ -2,52 +2,56 @@ package types
 
 type Double float64
 
-func (b *Double) DeserializeBoolean(v bool) {
-	panic("Unable to assign boolean to double field")
+func (b *Double) DeserializeBoolean(v bool) error {
+	return unsupported("Unable to assign boolean to double field")
 }
 
-func (b *Double) DeserializeInt(v int32) {
+func (b *Double) DeserializeInt(v int32) error {
 	*(*float64)(b) = float64(v)
+	return nil
 }
 
-func (b *Double) DeserializeLong(v int64) {
+func (b *Double) DeserializeLong(v int64) error {
 	*(*float64)(b) = float64(v)
+	return nil
 }
 
-func (b *Double) DeserializeFloat(v float32) {
+func (b *Double) DeserializeFloat(v float32) error {
 	*(*float64)(b) = float64(v)
+	return nil
 }
 
-func (b *Double) DeserializeDouble(v float64) {
+func (b *Double) DeserializeDouble(v float64) error {
 	*(*float64)(b) = v
+	return nil
 }
 
-func (b *Double) SetUnionElem(v int64) {
-	panic("Unable to assign union elem to double field")
+func (b *Double) SetUnionElem(v int64) error {
+	return unsupported("Unable to assign union elem to double field")
 }
 
-func (b *Double) DeserializeBytes(v []byte) {
-	panic("Unable to assign bytes to double field")
+func (b *Double) DeserializeBytes(v []byte) error {
+	return unsupported("Unable to assign bytes to double field")
 }
 
-func (b *Double) DeserializeString(v string) {
-	panic("Unable to assign string to double field")
+func (b *Double) DeserializeString(v string) error {
+	return unsupported("Unable to assign string to double field")
 }
 
-func (b *Double) Get(i int) Field {
-	panic("Unable to get field from double field")
+func (b *Double) Get(i int) (Field, error) {
+	return nil, unsupported("Unable to get field from double field")
 }
 
-func (b *Double) SetDefault(i int) {
-	panic("Unable to set default on double field")
+func (b *Double) SetDefault(i int) error {
+	return unsupported("Unable to set default on double field")
 }
 
-func (b *Double) AppendMap(key string) Field {
-	panic("Unable to append map key to from double field")
+func (b *Double) AppendMap(key string) (Field, error) {
+	return nil, unsupported("Unable to append map key to from double field")
 }
 
-func (b *Double) AppendArray() Field {
-	panic("Unable to append array element to from double field")
+func (b *Double) AppendArray() (Field, error) {
+	return nil, unsupported("Unable to append array element to from double field")
 }
 
-func (b *Double) Finalize() {}
+func (b *Double) Finalize() error { return nil }
@@ -1,27 +1,48 @@
 // Wrappers for Avro primitive types implementing the methods required by GADGT
 package types
 
+import (
+	"errors"
+)
+
 // The interface neeed by GADGT to enter and set fields on a type
-// Most types only need to implement a subset
+// Most types only need to implement a subset, and return ErrUnsupportedOperation for the rest
 type Field interface {
 	// Assign a primitive field
-	DeserializeBoolean(v bool)
-	DeserializeInt(v int32)
-	DeserializeLong(v int64)
-	DeserializeFloat(v float32)
-	DeserializeDouble(v float64)
-	DeserializeBytes(v []byte)
-	DeserializeString(v string)
+	DeserializeBoolean(v bool) error
+	DeserializeInt(v int32) error
+	DeserializeLong(v int64) error
+	DeserializeFloat(v float32) error
+	DeserializeDouble(v float64) error
+	DeserializeBytes(v []byte) error
+	DeserializeString(v string) error
 
 	// Get a nested field
-	Get(i int) Field
+	Get(i int) (Field, error)
 	// Set the default value for a given field
-	SetDefault(i int)
+	SetDefault(i int) error
 
 	// Append a new value to a map or array and enter it
-	AppendMap(key string) Field
-	AppendArray() Field
+	AppendMap(key string) (Field, error)
+	AppendArray() (Field, error)
 
 	// Finalize a field if necessary
-	Finalize()
+	Finalize() error
+}
+
+// ErrUnsupportedOperation is returned by a Field for an operation that doesn't apply to its type,
+// like assigning a string to an int field. The primitive types wrap it in a more specific message.
+var ErrUnsupportedOperation = errors.New("Unsupported operation")
+
+// ErrUnknownFieldIndex is returned by Get and SetDefault for an index the type doesn't have
+var ErrUnknownFieldIndex = errors.New("Unknown field index")
+
+type unsupported string
+
+func (e unsupported) Error() string {
+	return string(e)
+}
+
+func (e unsupported) Unwrap() error {
+	return ErrUnsupportedOperation
 }
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"fmt"
+)
+
+// LegacyField is the interface targets implemented before Field methods returned errors,
+// when misuse was reported by panicking. Nested fields are still returned as a Field,
+// so a legacy type can return the primitive wrappers in this package directly,
+// and other legacy types by wrapping them with WrapLegacy.
+type LegacyField interface {
+	DeserializeBoolean(v bool)
+	DeserializeInt(v int32)
+	DeserializeLong(v int64)
+	DeserializeFloat(v float32)
+	DeserializeDouble(v float64)
+	DeserializeBytes(v []byte)
+	DeserializeString(v string)
+
+	Get(i int) Field
+	SetDefault(i int)
+
+	AppendMap(key string) Field
+	AppendArray() Field
+
+	Finalize()
+}
+
+// WrapLegacy adapts a LegacyField to the Field interface, so it can be passed to vm.Eval.
+// Panics from the wrapped field are recovered and returned as errors.
+func WrapLegacy(f LegacyField) Field {
+	return &legacyField{f}
+}
+
+type legacyField struct {
+	f LegacyField
+}
+
+// recoverError turns a panic into the error returned by the deferring method
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("%v", r)
+		}
+	}
+}
+
+func (l *legacyField) DeserializeBoolean(v bool) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeBoolean(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeInt(v int32) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeInt(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeLong(v int64) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeLong(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeFloat(v float32) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeFloat(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeDouble(v float64) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeDouble(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeBytes(v []byte) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeBytes(v)
+	return nil
+}
+
+func (l *legacyField) DeserializeString(v string) (err error) {
+	defer recoverError(&err)
+	l.f.DeserializeString(v)
+	return nil
+}
+
+func (l *legacyField) Get(i int) (f Field, err error) {
+	defer recoverError(&err)
+	return l.f.Get(i), nil
+}
+
+func (l *legacyField) SetDefault(i int) (err error) {
+	defer recoverError(&err)
+	l.f.SetDefault(i)
+	return nil
+}
+
+func (l *legacyField) AppendMap(key string) (f Field, err error) {
+	defer recoverError(&err)
+	return l.f.AppendMap(key), nil
+}
+
+func (l *legacyField) AppendArray() (f Field, err error) {
+	defer recoverError(&err)
+	return l.f.AppendArray(), nil
+}
+
+func (l *legacyField) Finalize() (err error) {
+	defer recoverError(&err)
+	l.f.Finalize()
+	return nil
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A target written against the panicking interface
+type legacyRecord struct {
+	A int64
+	B []string
+}
+
+func (_ *legacyRecord) DeserializeBoolean(v bool)   { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeInt(v int32)      { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeLong(v int64)     { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeFloat(v float32)  { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeDouble(v float64) { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeBytes(v []byte)   { panic("Unsupported operation") }
+func (_ *legacyRecord) DeserializeString(v string)  { panic("Unsupported operation") }
+func (r *legacyRecord) Get(i int) Field {
+	switch i {
+	case 0:
+		return (*Long)(&r.A)
+	case 1:
+		return WrapLegacy((*legacyStrings)(&r.B))
+	}
+	panic("Unknown field index")
+}
+func (_ *legacyRecord) SetDefault(i int)           { panic("Unsupported operation") }
+func (_ *legacyRecord) AppendMap(key string) Field { panic("Unsupported operation") }
+func (_ *legacyRecord) AppendArray() Field         { panic("Unsupported operation") }
+func (_ *legacyRecord) Finalize()                  {}
+
+type legacyStrings []string
+
+func (_ *legacyStrings) DeserializeBoolean(v bool)   { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeInt(v int32)      { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeLong(v int64)     { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeFloat(v float32)  { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeDouble(v float64) { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeBytes(v []byte)   { panic("Unsupported operation") }
+func (_ *legacyStrings) DeserializeString(v string)  { panic("Unsupported operation") }
+func (_ *legacyStrings) Get(i int) Field             { panic("Unsupported operation") }
+func (_ *legacyStrings) SetDefault(i int)            { panic("Unsupported operation") }
+func (_ *legacyStrings) AppendMap(key string) Field  { panic("Unsupported operation") }
+func (r *legacyStrings) AppendArray() Field {
+	*r = append(*r, "")
+	return (*String)(&(*r)[len(*r)-1])
+}
+func (_ *legacyStrings) Finalize() {}
+
+func TestWrapLegacy(t *testing.T) {
+	record := &legacyRecord{}
+	f := WrapLegacy(record)
+
+	a, err := f.Get(0)
+	assert.Nil(t, err)
+	assert.Nil(t, a.DeserializeLong(5))
+
+	b, err := f.Get(1)
+	assert.Nil(t, err)
+	item, err := b.AppendArray()
+	assert.Nil(t, err)
+	assert.Nil(t, item.DeserializeString("x"))
+	assert.Nil(t, f.Finalize())
+
+	assert.Equal(t, &legacyRecord{A: 5, B: []string{"x"}}, record)
+}
+
+func TestWrapLegacyRecoversPanics(t *testing.T) {
+	f := WrapLegacy(&legacyRecord{})
+
+	err := f.DeserializeString("x")
+	assert.Equal(t, "Unsupported operation", err.Error())
+
+	_, err = f.Get(5)
+	assert.Equal(t, "Unknown field index", err.Error())
+
+	// Errors from the primitive types are returned as-is
+	a, _ := f.Get(0)
+	err = a.DeserializeString("x")
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation))
+}
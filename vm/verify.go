@@ -0,0 +1,207 @@
+package vm
+
+import (
+	"fmt"
+)
+
+// Strict makes Eval and EvalBytes refuse to run a program which fails Program.Verify.
+// An Evaluator verifies its program once, when it's created.
+func Strict() Option {
+	return func(c *evalConfig) {
+		c.strict = true
+	}
+}
+
+// Verify checks that the program is well-formed, so it can't jump out of the program or leave the VM
+// in an inconsistent state whatever the input. Programs produced by the compiler always pass.
+// It checks that:
+//
+//   - every opcode is known
+//   - Jump, CondJump, Call and block targets are inside the program, and a BlockEnd targets a block start
+//   - Halt operands index into Errors
+//   - Read, Skip, ReadSet, ReadSetField and Set operands are valid types, or fixed sizes where the op allows them
+//   - the ranges of the SkippedFields are inside the program
+//   - on every path through the program, each Enter, AppendArray and AppendMap is matched by an Exit,
+//     each loop that's pushed is popped by a PopLoop or BlockEnd and each PushDepth is matched by a PopDepth,
+//     and a method doesn't Return until everything it opened is closed
+func (p *Program) Verify() error {
+	for pc, inst := range p.Instructions {
+		if err := p.verifyInstruction(inst); err != nil {
+			return fmt.Errorf("Invalid program - %v at pc %v: %v", err, pc, inst)
+		}
+	}
+	for _, f := range p.SkippedFields {
+		if f.Start < 0 || f.Start > f.End || f.End > len(p.Instructions) {
+			return fmt.Errorf("Invalid program - skipped field %v range %v - %v out of range", f.Name, f.Start, f.End)
+		}
+	}
+	return p.verifyNesting()
+}
+
+func (p *Program) verifyInstruction(inst Instruction) error {
+	switch inst.Op {
+	case Jump, CondJump, Call, BlockStart, SkipBlockStart:
+		if inst.Operand < 0 || inst.Operand >= len(p.Instructions) {
+			return fmt.Errorf("target %v out of range", inst.Operand)
+		}
+	case BlockEnd:
+		if inst.Operand < 0 || inst.Operand >= len(p.Instructions) {
+			return fmt.Errorf("target %v out of range", inst.Operand)
+		}
+		if op := p.Instructions[inst.Operand].Op; op != BlockStart && op != SkipBlockStart {
+			return fmt.Errorf("target %v isn't a block start", inst.Operand)
+		}
+	case ReadSetField:
+		if index, t := splitFieldOperand(inst.Operand); index < 0 || !isValueType(t) {
+			return fmt.Errorf("invalid field %v or type %v", index, t)
+		}
+	case Halt:
+		if inst.Operand < 0 || inst.Operand > len(p.Errors) {
+			return fmt.Errorf("error %v out of range", inst.Operand)
+		}
+	case Read, Skip:
+		if !isValueType(inst.Operand) && inst.Operand != UnusedLong && !isFixedType(inst.Operand) {
+			return fmt.Errorf("invalid type %v", inst.Operand)
+		}
+	case ReadSet:
+		if !isValueType(inst.Operand) && !isFixedType(inst.Operand) {
+			return fmt.Errorf("invalid type %v", inst.Operand)
+		}
+	case Set:
+		if !isValueType(inst.Operand) {
+			return fmt.Errorf("invalid type %v", inst.Operand)
+		}
+	default:
+		if inst.Op < 0 || int(inst.Op) >= opCount {
+			return fmt.Errorf("unknown opcode %v", int(inst.Op))
+		}
+	}
+	return nil
+}
+
+// isValueType returns whether t is one of the types which are held in a register
+func isValueType(t int) bool {
+	return t >= Null && t <= String
+}
+
+// isFixedType returns whether t is the size of a fixed value
+func isFixedType(t int) bool {
+	return t > UnusedLong && t != NoopField
+}
+
+// The entries on the nesting stack tracked by verifyNesting
+const (
+	nestField = 'f'
+	nestLoop  = 'l'
+	nestDepth = 'd'
+)
+
+// verifyNesting follows every path from the start of the program and from each method, tracking the
+// fields, loops and PushDepths which are open. Each instruction must be reached with the same ones open on every path.
+// It assumes the jump targets have already been checked.
+func (p *Program) verifyNesting() error {
+	// The fields, loops and PushDepths open before each instruction, innermost last, for the instructions reached so far
+	open := make([]string, len(p.Instructions))
+	reached := make([]bool, len(p.Instructions))
+
+	type state struct {
+		pc    int
+		stack string
+	}
+	pending := []state{{0, ""}}
+	for _, inst := range p.Instructions {
+		if inst.Op == Call {
+			pending = append(pending, state{inst.Operand, ""})
+		}
+	}
+
+	for len(pending) > 0 {
+		s := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if s.pc >= len(p.Instructions) {
+			// Running off the end of the program returns, like a Return
+			if s.stack != "" {
+				return fmt.Errorf("Invalid program - end of program reached with %v still open", describeNesting(s.stack))
+			}
+			continue
+		}
+		if reached[s.pc] {
+			if open[s.pc] != s.stack {
+				return fmt.Errorf("Invalid program - pc %v reached with %v open and with %v open", s.pc, describeNesting(open[s.pc]), describeNesting(s.stack))
+			}
+			continue
+		}
+		reached[s.pc] = true
+		open[s.pc] = s.stack
+
+		inst := p.Instructions[s.pc]
+		stack := s.stack
+		switch inst.Op {
+		case Enter, AppendArray, AppendMap:
+			stack += string(nestField)
+		case PushLoop:
+			stack += string(nestLoop)
+		case PushDepth:
+			stack += string(nestDepth)
+		case BlockStart, SkipBlockStart:
+			// The array or map ends without entering the loop
+			pending = append(pending, state{inst.Operand, stack})
+			stack += string(nestLoop)
+		case Exit, PopLoop, BlockEnd, PopDepth:
+			expected := byte(nestLoop)
+			switch inst.Op {
+			case Exit:
+				expected = nestField
+			case PopDepth:
+				expected = nestDepth
+			}
+			if stack == "" || stack[len(stack)-1] != expected {
+				return fmt.Errorf("Invalid program - unmatched %v at pc %v", inst.Op, s.pc)
+			}
+			stack = stack[:len(stack)-1]
+			if inst.Op == BlockEnd {
+				// The loop continues after the BlockStart, or goes back to it to read the next block
+				pending = append(pending, state{inst.Operand, stack}, state{inst.Operand + 1, stack + string(nestLoop)})
+				continue
+			}
+		case Return:
+			if stack != "" {
+				return fmt.Errorf("Invalid program - return at pc %v with %v still open", s.pc, describeNesting(stack))
+			}
+			continue
+		case Halt:
+			if inst.Operand == 0 && stack != "" {
+				return fmt.Errorf("Invalid program - halt at pc %v with %v still open", s.pc, describeNesting(stack))
+			}
+			continue
+		case Jump:
+			pending = append(pending, state{inst.Operand, stack})
+			continue
+		case CondJump:
+			pending = append(pending, state{inst.Operand, stack})
+		}
+		pending = append(pending, state{s.pc + 1, stack})
+	}
+	return nil
+}
+
+func describeNesting(stack string) string {
+	if stack == "" {
+		return "nothing"
+	}
+	fields, loops, depths := 0, 0, 0
+	for _, c := range stack {
+		switch c {
+		case nestField:
+			fields++
+		case nestLoop:
+			loops++
+		default:
+			depths++
+		}
+	}
+	if depths > 0 {
+		return fmt.Sprintf("%v fields, %v loops and %v depth markers", fields, loops, depths)
+	}
+	return fmt.Sprintf("%v fields and %v loops", fields, loops)
+}
@@ -0,0 +1,120 @@
+package vm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/clear-street/gogen-avro/compiler"
+	"github.com/clear-street/gogen-avro/vm"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCompiledPrograms(t *testing.T) {
+	assert.Nil(t, compileTestRecord(t).Verify())
+
+	program, err := compiler.CompileSchemaBytes([]byte(linkedListSchema), []byte(linkedListSchema))
+	assert.Nil(t, err)
+	assert.Nil(t, program.Verify())
+}
+
+func TestVerifyInvalidPrograms(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		instructions []vm.Instruction
+		err          string
+	}{
+		{"unknown opcode", []vm.Instruction{{Op: vm.Op(200)}}, "Invalid program - unknown opcode 200 at pc 0"},
+		{"jump out of range", []vm.Instruction{{Op: vm.Jump, Operand: 2}, {Op: vm.Halt}}, "Invalid program - target 2 out of range at pc 0"},
+		{"negative call", []vm.Instruction{{Op: vm.Call, Operand: -1}}, "Invalid program - target -1 out of range at pc 0"},
+		{"halt error out of range", []vm.Instruction{{Op: vm.Halt, Operand: 2}}, "Invalid program - error 2 out of range at pc 0"},
+		{"read union", []vm.Instruction{{Op: vm.Read, Operand: vm.UnionElem}}, "Invalid program - invalid type 9 at pc 0"},
+		{"read unused", []vm.Instruction{{Op: vm.Read, Operand: vm.Unused}}, "Invalid program - invalid type 0 at pc 0"},
+		{"set fixed", []vm.Instruction{{Op: vm.Set, Operand: 11 + 4}}, "Invalid program - invalid type 15 at pc 0"},
+		{"read_set unused long", []vm.Instruction{{Op: vm.ReadSet, Operand: vm.UnusedLong}}, "Invalid program - invalid type 10 at pc 0"},
+		{
+			"unmatched exit",
+			[]vm.Instruction{{Op: vm.Exit, Operand: vm.NoopField}},
+			"Invalid program - unmatched exit at pc 0",
+		},
+		{
+			"exit closing a loop",
+			[]vm.Instruction{{Op: vm.PushLoop}, {Op: vm.Exit, Operand: vm.NoopField}},
+			"Invalid program - unmatched exit at pc 1",
+		},
+		{
+			"enter without exit",
+			[]vm.Instruction{{Op: vm.Enter}, {Op: vm.Halt}},
+			"Invalid program - halt at pc 1 with 1 fields and 0 loops still open",
+		},
+		{
+			"method returns inside a loop",
+			[]vm.Instruction{{Op: vm.Call, Operand: 2}, {Op: vm.Halt}, {Op: vm.PushLoop}, {Op: vm.Return, Operand: vm.NoopField}},
+			"Invalid program - return at pc 3 with 0 fields and 1 loops still open",
+		},
+		{
+			"unmatched pop depth",
+			[]vm.Instruction{{Op: vm.Enter}, {Op: vm.PopDepth, Operand: vm.NoopField}},
+			"Invalid program - unmatched pop_depth at pc 1",
+		},
+		{
+			"method returns with a depth pushed",
+			[]vm.Instruction{{Op: vm.Call, Operand: 2}, {Op: vm.Halt}, {Op: vm.PushDepth, Operand: vm.NoopField}, {Op: vm.Return, Operand: vm.NoopField}},
+			"Invalid program - return at pc 3 with 0 fields, 0 loops and 1 depth markers still open",
+		},
+		{
+			"falls off the end",
+			[]vm.Instruction{{Op: vm.Enter}},
+			"Invalid program - end of program reached with 1 fields and 0 loops still open",
+		},
+		{
+			"paths disagree",
+			[]vm.Instruction{
+				{Op: vm.CondJump, Operand: 2},
+				{Op: vm.Enter},
+				{Op: vm.Exit, Operand: vm.NoopField},
+				{Op: vm.Halt},
+			},
+			"Invalid program - pc 2 reached with 1 fields and 0 loops open and with nothing open",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			program := &vm.Program{Instructions: c.instructions, Errors: []string{"error"}}
+			err := program.Verify()
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	program := compileTestRecord(t)
+	buf := encodeTestRecord(testRecordFixture)
+
+	// A valid program runs as usual
+	record := &testRecord{}
+	_, err := vm.EvalBytes(buf, program, record, vm.Strict())
+	assert.Nil(t, err)
+	assert.Equal(t, testRecordFixture, record)
+
+	// Replacing the first Exit with a no-op leaves its field open
+	invalid := &vm.Program{Instructions: append([]vm.Instruction{}, program.Instructions...), Errors: program.Errors}
+	for i, inst := range invalid.Instructions {
+		if inst.Op == vm.Exit {
+			invalid.Instructions[i] = vm.Instruction{Op: vm.Skip, Operand: vm.Null}
+			break
+		}
+	}
+	verifyErr := invalid.Verify()
+	assert.NotNil(t, verifyErr)
+
+	_, err = vm.EvalBytes(buf, invalid, &testRecord{}, vm.Strict())
+	assert.Equal(t, verifyErr, err)
+	assert.Equal(t, verifyErr, vm.Eval(bytes.NewReader(buf), invalid, &testRecord{}, vm.Strict()))
+
+	evaluator := vm.NewEvaluator(invalid, vm.Strict())
+	n, err := evaluator.EvalBytes(buf, &testRecord{})
+	assert.Equal(t, 0, n)
+	assert.Equal(t, verifyErr, err)
+}